@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/theMagicalKarp/agronomist/pkg/builtins"
+	"github.com/theMagicalKarp/agronomist/pkg/policy"
+)
+
+func EvalCMD() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "eval",
+		Short: "Evaluate a scaling policy against an input document",
+		Args:  cobra.NoArgs,
+		RunE:  Eval,
+
+		SilenceUsage: true,
+	}
+
+	flags := command.Flags()
+	flags.StringP(
+		"policy", "p", "", "Path to a ScalingPolicy manifest, may also contain RegoLibrary and ConfigMap manifests",
+	)
+	flags.StringP(
+		"input", "i", "", "Path to a JSON or YAML input document",
+	)
+	flags.StringSliceP(
+		"manifests", "m", nil, "Paths to additional RegoLibrary and ConfigMap manifests",
+	)
+	flags.StringP(
+		"name", "", "", "Name of the ScalingPolicy to evaluate when the manifest contains several",
+	)
+	flags.IntP(
+		"replicas", "r", -1, "Current replica count of every target, defaults to input.target.spec.replicas or input.targets.<name>.target.spec.replicas",
+	)
+
+	flags.BoolP(
+		"explain", "", false, "Print the rego trace and trace() notes, as stored in the status by spec.debug",
+	)
+
+	command.MarkFlagRequired("policy")
+	command.MarkFlagRequired("input")
+
+	return command
+}
+
+func Eval(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	policyPath, _ := flags.GetString("policy")
+	inputPath, _ := flags.GetString("input")
+	manifestPaths, _ := flags.GetStringSlice("manifests")
+	name, _ := flags.GetString("name")
+	replicas, _ := flags.GetInt("replicas")
+	explain, _ := flags.GetBool("explain")
+
+	builtins.Register()
+
+	ctx := context.Background()
+
+	sp, err := LoadScalingPolicy(ctx, name, append([]string{policyPath}, manifestPaths...)...)
+	if err != nil {
+		return err
+	}
+
+	input, err := ReadDocument(inputPath)
+	if err != nil {
+		return err
+	}
+
+	current := InputTargetReplicas(sp, input)
+	if replicas >= 0 {
+		for name := range current {
+			current[name] = replicas
+		}
+	}
+
+	if explain {
+		sp.Debug = true
+	}
+
+	rs, decision, err := sp.Evaluate(ctx, input)
+
+	if sp.Debug && sp.LastTrace != nil {
+		fmt.Printf("trace:\n%s\n", sp.LastTrace.Trace)
+		for _, note := range sp.LastTrace.Notes {
+			fmt.Printf("note: %s\n", note)
+		}
+	}
+
+	if rs != nil {
+		raw, marshalErr := json.MarshalIndent(rs, "", "  ")
+		if marshalErr != nil {
+			return marshalErr
+		}
+		fmt.Printf("result:\n%s\n", raw)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("decision: %s\n", decision)
+	for _, pt := range sp.Targets {
+		scale := pt.Normalize(decision.TargetReplicas(pt.Name), current[pt.Name])
+		if pt.Name == "" {
+			fmt.Printf("replicas: %d -> %d\n", current[pt.Name], scale)
+			continue
+		}
+		fmt.Printf("replicas: %s %d -> %d\n", pt.Name, current[pt.Name], scale)
+	}
+
+	return nil
+}
+
+func LoadScalingPolicy(ctx context.Context, name string, paths ...string) (*policy.ScalingPolicy, error) {
+	objs, err := ReadManifests(paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *unstructured.Unstructured
+	for _, obj := range FindScalingPolicies(objs) {
+		if name == "" || obj.GetName() == name {
+			target = obj
+			break
+		}
+	}
+
+	if target == nil {
+		return nil, fmt.Errorf("no ScalingPolicy %s found in %v", name, paths)
+	}
+
+	resolver, err := CreateManifestResolver(objs)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy.CreateScalingPolicy(target, resolver)
+}
+
+// InputReplicas mirrors Scale, which treats a target without spec.replicas
+// as having a single replica. Inputs captured before targetRef existed keep
+// the workload under `deployment`.
+func InputReplicas(input map[string]interface{}) int {
+	if input == nil {
+		return 1
+	}
+
+	replicas, exists, err := unstructured.NestedFieldNoCopy(input, "target", "spec", "replicas")
+	if err == nil && !exists {
+		replicas, exists, err = unstructured.NestedFieldNoCopy(input, "deployment", "spec", "replicas")
+	}
+	if err != nil || !exists {
+		return 1
+	}
+
+	switch v := replicas.(type) {
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case json.Number:
+		n, err := v.Int64()
+		if err == nil {
+			return int(n)
+		}
+	}
+
+	return 1
+}
+
+// InputTargetReplicas reads the current replicas of each of the policy's
+// targets from an input document, named targets living under `targets`.
+func InputTargetReplicas(sp *policy.ScalingPolicy, input map[string]interface{}) map[string]int {
+	replicas := make(map[string]int)
+
+	for _, pt := range sp.Targets {
+		if pt.Name == "" {
+			replicas[pt.Name] = InputReplicas(input)
+			continue
+		}
+
+		targetInput, _, _ := unstructured.NestedFieldNoCopy(input, "targets", pt.Name)
+		targetMap, _ := targetInput.(map[string]interface{})
+		replicas[pt.Name] = InputReplicas(targetMap)
+	}
+
+	return replicas
+}
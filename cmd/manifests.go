@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/theMagicalKarp/agronomist/pkg/storage"
+)
+
+type ManifestResolver struct {
+	Libraries   map[string]*unstructured.Unstructured
+	ConfigMaps  map[string]*coreV1.ConfigMap
+	BundleCache *storage.BundleCache
+}
+
+func CreateManifestResolver(objs []*unstructured.Unstructured) (*ManifestResolver, error) {
+	resolver := &ManifestResolver{
+		Libraries:   make(map[string]*unstructured.Unstructured),
+		ConfigMaps:  make(map[string]*coreV1.ConfigMap),
+		BundleCache: storage.CreateBundleCache(http.DefaultClient, time.Minute),
+	}
+
+	for _, obj := range objs {
+		key := fmt.Sprintf("%s/%s", obj.GetNamespace(), obj.GetName())
+
+		switch obj.GetKind() {
+		case "RegoLibrary":
+			resolver.Libraries[key] = obj
+		case "ConfigMap":
+			configMap := &coreV1.ConfigMap{}
+			err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, configMap)
+			if err != nil {
+				return nil, err
+			}
+			resolver.ConfigMaps[key] = configMap
+		}
+	}
+
+	return resolver, nil
+}
+
+func (m *ManifestResolver) GetRegoLibrary(namespace, name string) (*unstructured.Unstructured, bool, error) {
+	library, exists := m.Libraries[fmt.Sprintf("%s/%s", namespace, name)]
+	return library, exists, nil
+}
+
+func (m *ManifestResolver) GetConfigMap(namespace, name string) (*coreV1.ConfigMap, bool, error) {
+	configMap, exists := m.ConfigMaps[fmt.Sprintf("%s/%s", namespace, name)]
+	return configMap, exists, nil
+}
+
+// GetBundle fetches bundles on first use, since there is no poller running
+// outside the controller.
+func (m *ManifestResolver) GetBundle(ctx context.Context, url string) (*storage.BundleEntry, error) {
+	if m.BundleCache.GetBundleVersion(url) == "" {
+		err := m.BundleCache.Fetch(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m.BundleCache.GetBundle(ctx, url)
+}
+
+func (m *ManifestResolver) GetBundleVersion(url string) string {
+	return m.BundleCache.GetBundleVersion(url)
+}
+
+func ReadManifests(paths ...string) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
+		for {
+			var raw json.RawMessage
+			err = decoder.Decode(&raw)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			if len(raw) == 0 || string(raw) == "null" {
+				continue
+			}
+
+			var typeMeta metav1.TypeMeta
+			err = json.Unmarshal(raw, &typeMeta)
+			if err != nil || typeMeta.Kind == "" {
+				continue
+			}
+
+			obj := &unstructured.Unstructured{}
+			err = obj.UnmarshalJSON(raw)
+			if err != nil {
+				file.Close()
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			objs = append(objs, obj)
+		}
+
+		file.Close()
+	}
+
+	return objs, nil
+}
+
+func ReadDocument(path string) (map[string]interface{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	document := make(map[string]interface{})
+	err = yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&document)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return document, nil
+}
+
+func FindScalingPolicies(objs []*unstructured.Unstructured) []*unstructured.Unstructured {
+	var policies []*unstructured.Unstructured
+	for _, obj := range objs {
+		if obj.GetKind() == "ScalingPolicy" {
+			policies = append(policies, obj)
+		}
+	}
+	return policies
+}
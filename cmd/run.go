@@ -11,21 +11,22 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
-	"github.com/open-policy-agent/opa/ast"
-	"github.com/open-policy-agent/opa/rego"
-	"github.com/open-policy-agent/opa/types"
-
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/scale"
 	"k8s.io/client-go/tools/clientcmd"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+	"k8s.io/metrics/pkg/client/custom_metrics"
+	"k8s.io/metrics/pkg/client/external_metrics"
 
+	"github.com/theMagicalKarp/agronomist/pkg/builtins"
 	"github.com/theMagicalKarp/agronomist/pkg/reconciler"
 	"github.com/theMagicalKarp/agronomist/pkg/storage"
 )
@@ -37,11 +38,17 @@ func RootCMD() *cobra.Command {
 		Run:   Run,
 	}
 
-	flags := command.Flags()
-	flags.StringP(
+	command.AddCommand(EvalCMD())
+	command.AddCommand(SnapshotCMD())
+	command.AddCommand(TestCMD())
+	command.AddCommand(SimulateCMD())
+
+	persistentFlags := command.PersistentFlags()
+	persistentFlags.StringP(
 		"kubeconfig", "", "", "Path to kubeconfig/assumes in-cluster if not provided",
 	)
 
+	flags := command.Flags()
 	flags.StringP(
 		"pod", "", "local", "Name of pod which agronomist is running in",
 	)
@@ -52,35 +59,72 @@ func RootCMD() *cobra.Command {
 		"namespace", "", "kube-system", "Namespace of which agronomist is running in",
 	)
 
+	flags.IntP(
+		"bundle-poll-interval", "", 60, "Seconds between polls of OPA bundles referenced by scaling policies",
+	)
+
+	flags.IntP(
+		"metrics-period", "", 15, "Seconds a namespace's pod metrics list is shared between scaling policies before it is listed again",
+	)
+	flags.IntP(
+		"metrics-max-age", "", 120, "Seconds past its window after which a pod metrics sample is considered stale",
+	)
+
+	flags.IntP(
+		"eval-timeout", "", 10, "Default seconds a scaling policy evaluation may run before it is cancelled",
+	)
+	flags.Int64P(
+		"eval-budget", "", 0, "Default number of evaluation steps a scaling policy may take, 0 for unlimited",
+	)
+
+	flags.StringSliceP(
+		"resource-namespaces", "", nil, "Namespaces every scaling policy may read resource inputs from, besides its own",
+	)
+
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
+	viper.BindPFlags(persistentFlags)
 	viper.BindPFlags(flags)
 
 	return command
 }
 
 func Run(cmd *cobra.Command, args []string) {
-	rego.RegisterBuiltin1(
-		&rego.Function{
-			Name: "parseunit",
-			Decl: types.NewFunction(types.Args(types.S), types.N),
-		},
-		func(_ rego.BuiltinContext, a *ast.Term) (*ast.Term, error) {
-			if str, ok := a.Value.(ast.String); ok {
-				quantity, err := resource.ParseQuantity(string(str))
-
-				if err != nil {
-					return nil, nil
-				}
-				return ast.IntNumberTerm(int(quantity.ScaledValue(resource.Milli))), nil
-			}
-			return nil, nil
-		},
-	)
+	builtins.Register()
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	kubeconfig := viper.GetString("kubeconfig")
+	store, err := CreateStore(viper.GetString("kubeconfig"))
+	if err != nil {
+		panic(err)
+	}
+	store.BundleCache.Interval = time.Duration(viper.GetInt("bundle-poll-interval")) * time.Second
+	store.PodMetricsCache.Period = time.Duration(viper.GetInt("metrics-period")) * time.Second
+	store.PodMetricsCache.MaxAge = time.Duration(viper.GetInt("metrics-max-age")) * time.Second
+	store.Start(ctx)
+
+	scalingPolicyReconciler := reconciler.CreateScalingPolicyReconciler(
+		viper.GetString("namespace"),
+		viper.GetString("pod"),
+		k8stypes.UID(viper.GetString("pod-uid")),
+		store,
+	)
+
+	scalingPolicyReconciler.PolicyRegistry.EvalTimeout = time.Duration(viper.GetInt("eval-timeout")) * time.Second
+	scalingPolicyReconciler.PolicyRegistry.EvalBudget = viper.GetInt64("eval-budget")
+	scalingPolicyReconciler.PolicyRegistry.ResourceNamespaces = viper.GetStringSlice("resource-namespaces")
+
+	go scalingPolicyReconciler.Start(ctx)
+
+	fmt.Println("Starting!")
+
+	sigCh := make(chan os.Signal, 0)
+	signal.Notify(sigCh, os.Kill, os.Interrupt)
+	<-sigCh
+	cancel()
+}
+
+func CreateStore(kubeconfig string) (*storage.Store, error) {
 	var config *rest.Config
 	var err error
 
@@ -91,22 +135,22 @@ func Run(cmd *cobra.Command, args []string) {
 	}
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	metricsClientset, err := metricsv.NewForConfig(config)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	dynamicClientset, err := dynamic.NewForConfig(config)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	dynamicFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClientset, 0, metav1.NamespaceAll, nil)
@@ -114,21 +158,22 @@ func Run(cmd *cobra.Command, args []string) {
 	factory := informers.NewSharedInformerFactory(clientset, time.Hour*24)
 
 	store := storage.NewStore(clientset, metricsClientset, dynamicClientset, factory, dynamicFactory)
-	store.Start(ctx)
 
-	scalingPolicyReconciler := reconciler.CreateScalingPolicyReconciler(
-		viper.GetString("namespace"),
-		viper.GetString("pod"),
-		k8stypes.UID(viper.GetString("pod-uid")),
-		store,
-	)
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+	store.CustomMetricsAPIs = custom_metrics.NewAvailableAPIsGetter(clientset.Discovery())
+	store.CustomMetricsClient = custom_metrics.NewForConfig(config, mapper, store.CustomMetricsAPIs)
 
-	go scalingPolicyReconciler.Start(ctx)
+	store.ExternalMetricsClient, err = external_metrics.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
 
-	fmt.Println("Starting!")
+	store.RESTMapper = mapper
+	store.ResourceCache.RESTMapper = mapper
+	store.ScaleClient, err = scale.NewForConfig(config, mapper, dynamic.LegacyAPIPathResolverFunc, scale.NewDiscoveryScaleKindResolver(clientset.Discovery()))
+	if err != nil {
+		return nil, err
+	}
 
-	sigCh := make(chan os.Signal, 0)
-	signal.Notify(sigCh, os.Kill, os.Interrupt)
-	<-sigCh
-	cancel()
+	return store, nil
 }
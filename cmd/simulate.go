@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/theMagicalKarp/agronomist/pkg/builtins"
+	"github.com/theMagicalKarp/agronomist/pkg/policy"
+	"github.com/theMagicalKarp/agronomist/pkg/simulator"
+)
+
+func SimulateCMD() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "simulate",
+		Short: "Replay a metric history through a scaling policy",
+		Long: `Replay a metric history through a scaling policy.
+
+Samples are read from a CSV file with a header naming the time, cpu and
+optionally memory columns, or from a JSON/YAML list of {time, cpu, memory}
+objects. Times are in seconds and usage values are Kubernetes quantities. A
+virtual clock steps at the policy's interval, and each tick is evaluated,
+normalized and throttled as the controller would.`,
+		Args: cobra.NoArgs,
+		RunE: Simulate,
+
+		SilenceUsage: true,
+	}
+
+	flags := command.Flags()
+	flags.StringP(
+		"policy", "p", "", "Path to a ScalingPolicy manifest, may also contain RegoLibrary and ConfigMap manifests",
+	)
+	flags.StringSliceP(
+		"manifests", "m", nil, "Paths to additional RegoLibrary and ConfigMap manifests",
+	)
+	flags.StringP(
+		"name", "", "", "Name of the ScalingPolicy to simulate when the manifest contains several",
+	)
+	flags.StringP(
+		"samples", "s", "", "Path to a CSV, JSON or YAML series of usage samples",
+	)
+	flags.IntP(
+		"replicas", "r", -1, "Replica count of each target at the start of the simulation, defaults to the target's min",
+	)
+	flags.StringP(
+		"load", "", simulator.LoadPerPod, "How samples are applied to pods, `per-pod` usage or `total` usage split across pods",
+	)
+	flags.StringP(
+		"cpu-limit", "", "1", "CPU limit of each simulated pod",
+	)
+	flags.StringP(
+		"memory-limit", "", "", "Memory limit of each simulated pod",
+	)
+	flags.StringP(
+		"cpu-request", "", "", "CPU request of each simulated pod, defaults to the limit",
+	)
+	flags.StringP(
+		"memory-request", "", "", "Memory request of each simulated pod, defaults to the limit",
+	)
+	flags.StringP(
+		"chart", "", "", "Render a chart of replicas over time, `ascii` or `svg`",
+	)
+	flags.StringP(
+		"chart-output", "", "", "Path to write the chart to, defaults to stdout",
+	)
+
+	command.MarkFlagRequired("policy")
+	command.MarkFlagRequired("samples")
+
+	return command
+}
+
+func Simulate(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	policyPath, _ := flags.GetString("policy")
+	manifestPaths, _ := flags.GetStringSlice("manifests")
+	name, _ := flags.GetString("name")
+	samplesPath, _ := flags.GetString("samples")
+	replicas, _ := flags.GetInt("replicas")
+	load, _ := flags.GetString("load")
+	chart, _ := flags.GetString("chart")
+	chartOutput, _ := flags.GetString("chart-output")
+
+	if chart != "" && chart != "ascii" && chart != "svg" {
+		return fmt.Errorf("unknown chart `%s`, expected `ascii` or `svg`", chart)
+	}
+
+	cpuLimit, _ := flags.GetString("cpu-limit")
+	memoryLimit, _ := flags.GetString("memory-limit")
+	cpuRequest, _ := flags.GetString("cpu-request")
+	memoryRequest, _ := flags.GetString("memory-request")
+
+	resources, err := SimulatedResources(cpuLimit, memoryLimit, cpuRequest, memoryRequest)
+	if err != nil {
+		return err
+	}
+
+	builtins.Register()
+
+	ctx := context.Background()
+
+	sp, err := LoadScalingPolicy(ctx, name, append([]string{policyPath}, manifestPaths...)...)
+	if err != nil {
+		return err
+	}
+
+	samples, err := simulator.ReadSamples(samplesPath)
+	if err != nil {
+		return err
+	}
+
+	simulation, err := simulator.CreateSimulation(sp, replicas, load, resources)
+	if err != nil {
+		return err
+	}
+
+	steps, err := simulation.Run(ctx, samples)
+	if err != nil {
+		return err
+	}
+
+	err = PrintTimeline(os.Stdout, steps)
+	if err != nil {
+		return err
+	}
+
+	if chart == "" {
+		return nil
+	}
+
+	var w io.Writer = os.Stdout
+	if chartOutput != "" {
+		file, err := os.Create(chartOutput)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	} else {
+		fmt.Println()
+	}
+
+	if chart == "svg" {
+		return simulator.RenderSVG(w, steps)
+	}
+	return simulator.RenderASCII(w, steps)
+}
+
+func SimulatedResources(cpuLimit, memoryLimit, cpuRequest, memoryRequest string) (coreV1.ResourceRequirements, error) {
+	if cpuRequest == "" {
+		cpuRequest = cpuLimit
+	}
+	if memoryRequest == "" {
+		memoryRequest = memoryLimit
+	}
+
+	resources := coreV1.ResourceRequirements{
+		Limits:   coreV1.ResourceList{},
+		Requests: coreV1.ResourceList{},
+	}
+
+	for _, r := range []struct {
+		list  coreV1.ResourceList
+		name  coreV1.ResourceName
+		value string
+	}{
+		{resources.Limits, coreV1.ResourceCPU, cpuLimit},
+		{resources.Limits, coreV1.ResourceMemory, memoryLimit},
+		{resources.Requests, coreV1.ResourceCPU, cpuRequest},
+		{resources.Requests, coreV1.ResourceMemory, memoryRequest},
+	} {
+		if r.value == "" {
+			continue
+		}
+
+		quantity, err := resource.ParseQuantity(r.value)
+		if err != nil {
+			return resources, fmt.Errorf("invalid %s quantity %q: %v", r.name, r.value, err)
+		}
+		r.list[r.name] = quantity
+	}
+
+	return resources, nil
+}
+
+func PrintTimeline(w io.Writer, steps []simulator.Step) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tCPU\tMEMORY\tDECISION\tREPLICAS\tNOTE")
+
+	for _, step := range steps {
+		decision := "-"
+		if step.Decision != nil {
+			decision = step.Decision.String()
+		}
+
+		note := step.Hold
+		if step.Err != nil {
+			note = step.Err.Error()
+		}
+		if step.From != step.To {
+			note = fmt.Sprintf("scaled from %d", step.From)
+			if step.Targets != nil {
+				note = fmt.Sprintf("scaled from %d in total", step.From)
+			}
+		}
+
+		replicas := fmt.Sprintf("%d", step.To)
+		if step.Targets != nil {
+			replicas = policy.FormatTargetReplicas(step.Targets)
+		}
+
+		fmt.Fprintf(tw, "%vs\t%s\t%s\t%s\t%s\t%s\n", step.Time.Seconds(), step.Sample.CPU, step.Sample.Memory, decision, replicas, note)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/theMagicalKarp/agronomist/pkg/builtins"
+	"github.com/theMagicalKarp/agronomist/pkg/policy"
+	"github.com/theMagicalKarp/agronomist/pkg/storage"
+)
+
+// SnapshotResolver reads a policy's sources straight from the cluster, so a
+// snapshot doesn't wait on cluster-wide caches or the bundle poller.
+type SnapshotResolver struct {
+	Context context.Context
+	Store   *storage.Store
+}
+
+func (r *SnapshotResolver) GetRegoLibrary(namespace, name string) (*unstructured.Unstructured, bool, error) {
+	library, err := r.Store.DynamicClientset.Resource(schema.GroupVersionResource{
+		Group:    "agronomist.io",
+		Version:  "v1",
+		Resource: "regolibraries",
+	}).Namespace(namespace).Get(r.Context, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return library, true, nil
+}
+
+func (r *SnapshotResolver) GetConfigMap(namespace, name string) (*coreV1.ConfigMap, bool, error) {
+	configMap, err := r.Store.ClientSet.CoreV1().ConfigMaps(namespace).Get(r.Context, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return configMap, true, nil
+}
+
+// GetBundle fetches bundles on first use, like ManifestResolver, since the
+// poller isn't running.
+func (r *SnapshotResolver) GetBundle(ctx context.Context, url string) (*storage.BundleEntry, error) {
+	if r.Store.BundleCache.GetBundleVersion(url) == "" {
+		err := r.Store.BundleCache.Fetch(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.Store.BundleCache.GetBundle(ctx, url)
+}
+
+func (r *SnapshotResolver) GetBundleVersion(url string) string {
+	return r.Store.BundleCache.GetBundleVersion(url)
+}
+
+func SnapshotCMD() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "snapshot NAMESPACE/NAME",
+		Short: "Capture the input a scaling policy would be evaluated against",
+		Args:  cobra.ExactArgs(1),
+		RunE:  Snapshot,
+
+		SilenceUsage: true,
+	}
+
+	flags := command.Flags()
+	flags.StringP(
+		"output", "o", "", "Path to write the input document to, defaults to stdout",
+	)
+
+	flags.StringP(
+		"status-namespace", "", "kube-system", "Namespace agronomist runs in, used to read the policy's persisted state",
+	)
+
+	flags.DurationP(
+		"timeout", "", 30*time.Second, "How long to wait on the cluster before giving up",
+	)
+
+	return command
+}
+
+func Snapshot(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	statusNamespace, _ := cmd.Flags().GetString("status-namespace")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	parts := strings.SplitN(args[0], "/", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected NAMESPACE/NAME, got %s", args[0])
+	}
+	namespace, name := parts[0], parts[1]
+
+	builtins.Register()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	store, err := CreateStore(viper.GetString("kubeconfig"))
+	if err != nil {
+		return err
+	}
+
+	// only the caches a snapshot reads are started
+	informers := []cache.SharedIndexInformer{
+		store.DeploymentCache.Informer,
+		store.StatefulSetCache.Informer,
+		store.ReplicaSetCache.Informer,
+		store.PodCache.Informer,
+		store.ScalingPolicyStatusCache.Informer,
+	}
+	var synced []cache.InformerSynced
+	for _, informer := range informers {
+		go informer.Run(ctx.Done())
+		synced = append(synced, informer.HasSynced)
+	}
+	store.ResourceCache.Start(ctx)
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return fmt.Errorf("timed out waiting for caches to sync")
+	}
+
+	obj, err := store.DynamicClientset.Resource(schema.GroupVersionResource{
+		Group:    "agronomist.io",
+		Version:  "v1",
+		Resource: "scalingpolicies",
+	}).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return fmt.Errorf("%s/%s Scaling Policy DNE", namespace, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	sp, err := policy.CreateScalingPolicy(obj, &SnapshotResolver{Context: ctx, Store: store})
+	if err != nil {
+		return err
+	}
+
+	sp.StatusNamespace = statusNamespace
+	err = sp.LoadState(store)
+	if err != nil {
+		return err
+	}
+
+	err = sp.ResolveResourceScopes(store)
+	if err != nil {
+		return err
+	}
+
+	watched, err := sp.WatchedResources(store)
+	if err != nil {
+		return err
+	}
+	err = policy.WatchResources(watched, store)
+	if err != nil {
+		return err
+	}
+
+	targets, err := sp.GetTargets(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	input, err := sp.BuildInput(ctx, targets, store)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(input, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = fmt.Fprintf(os.Stdout, "%s\n", raw)
+		return err
+	}
+
+	return ioutil.WriteFile(output, append(raw, '\n'), 0644)
+}
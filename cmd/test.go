@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/open-policy-agent/opa/tester"
+	"github.com/spf13/cobra"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/theMagicalKarp/agronomist/pkg/builtins"
+	"github.com/theMagicalKarp/agronomist/pkg/policy"
+)
+
+type Fixture struct {
+	Policy string        `json:"policy"`
+	Cases  []FixtureCase `json:"cases"`
+}
+
+type FixtureCase struct {
+	Name      string                 `json:"name"`
+	Input     map[string]interface{} `json:"input"`
+	InputFile string                 `json:"inputFile"`
+	Replicas  *int                   `json:"replicas"`
+	Expected  *FixtureReplicas       `json:"expected"`
+}
+
+// FixtureReplicas is a replica count, or a map of target name to replica
+// count for policies with several targets.
+type FixtureReplicas struct {
+	Replicas int
+	Targets  map[string]int
+}
+
+func (f *FixtureReplicas) UnmarshalJSON(raw []byte) error {
+	err := json.Unmarshal(raw, &f.Replicas)
+	if err == nil {
+		return nil
+	}
+
+	return json.Unmarshal(raw, &f.Targets)
+}
+
+type TestSuite struct {
+	Dir       string
+	Manifests []string
+	Fixtures  []string
+}
+
+type TestReport struct {
+	Passed int
+	Failed int
+}
+
+func (t *TestReport) Pass(format string, a ...interface{}) {
+	t.Passed++
+	fmt.Printf("PASS %s\n", fmt.Sprintf(format, a...))
+}
+
+func (t *TestReport) Fail(format string, a ...interface{}) {
+	t.Failed++
+	fmt.Printf("FAIL %s\n", fmt.Sprintf(format, a...))
+}
+
+func TestCMD() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "test [PATH...]",
+		Short: "Run scaling policy fixtures and rego tests",
+		Long: `Run scaling policy fixtures and rego tests.
+
+Each directory is searched for ScalingPolicy manifests and fixture files named
+*_test.yaml, *_test.yml or *_test.json. Every fixture case is evaluated and
+normalized exactly as the controller would, and any test_ rules in the
+policy's modules are run with the OPA test runner.`,
+		RunE: RunTests,
+
+		SilenceUsage: true,
+	}
+
+	flags := command.Flags()
+	flags.StringP(
+		"run", "", "", "Only run fixture cases and rego tests matching this regular expression",
+	)
+
+	return command
+}
+
+func RunTests(cmd *cobra.Command, args []string) error {
+	filter, _ := cmd.Flags().GetString("run")
+
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	_, err := regexp.Compile(filter)
+	if err != nil {
+		return err
+	}
+
+	builtins.Register()
+
+	suites, err := DiscoverTestSuites(args...)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	report := &TestReport{}
+
+	for _, suite := range suites {
+		err = suite.Run(ctx, filter, report)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%d passed, %d failed\n", report.Passed, report.Failed)
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d test(s) failed", report.Failed)
+	}
+
+	return nil
+}
+
+func IsFixture(path string) bool {
+	for _, suffix := range []string{"_test.yaml", "_test.yml", "_test.json"} {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func IsManifest(path string) bool {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		return !IsFixture(path)
+	}
+	return false
+}
+
+func DiscoverTestSuites(paths ...string) ([]*TestSuite, error) {
+	suites := make(map[string]*TestSuite)
+
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			dir := filepath.Dir(path)
+			suite := suites[dir]
+			if suite == nil {
+				suite = &TestSuite{Dir: dir}
+				suites[dir] = suite
+			}
+
+			if IsFixture(path) {
+				suite.Fixtures = append(suite.Fixtures, path)
+			} else if IsManifest(path) {
+				suite.Manifests = append(suite.Manifests, path)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var dirs []string
+	for dir, suite := range suites {
+		if len(suite.Manifests) > 0 {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+
+	var result []*TestSuite
+	for _, dir := range dirs {
+		result = append(result, suites[dir])
+	}
+
+	return result, nil
+}
+
+func (t *TestSuite) Run(ctx context.Context, filter string, report *TestReport) error {
+	objs, err := ReadManifests(t.Manifests...)
+	if err != nil {
+		return err
+	}
+
+	policyObjs := FindScalingPolicies(objs)
+	if len(policyObjs) == 0 {
+		return nil
+	}
+
+	resolver, err := CreateManifestResolver(objs)
+	if err != nil {
+		return err
+	}
+
+	policies := make(map[string]*policy.ScalingPolicy)
+	var names []string
+	for _, obj := range policyObjs {
+		sp, err := policy.CreateScalingPolicy(obj, resolver)
+		if err != nil {
+			report.Fail("%s: %v", filepath.Join(t.Dir, obj.GetName()), err)
+			continue
+		}
+		policies[sp.Name] = sp
+		names = append(names, sp.Name)
+	}
+
+	for _, name := range names {
+		err = RunRegoTests(ctx, filter, policies[name], report)
+		if err != nil {
+			report.Fail("%s: %v", filepath.Join(t.Dir, name), err)
+		}
+	}
+
+	for _, path := range t.Fixtures {
+		fixture, err := ReadFixture(path)
+		if err != nil {
+			report.Fail("%s: %v", path, err)
+			continue
+		}
+
+		policyName := fixture.Policy
+		if policyName == "" && len(policyObjs) == 1 {
+			policyName = policyObjs[0].GetName()
+		}
+
+		sp := policies[policyName]
+		if sp == nil {
+			report.Fail("%s: ScalingPolicy %q not found in %s", path, policyName, t.Dir)
+			continue
+		}
+
+		for i, fixtureCase := range fixture.Cases {
+			name := fixtureCase.Name
+			if name == "" {
+				name = fmt.Sprintf("case %d", i)
+			}
+
+			if matched, _ := regexp.MatchString(filter, name); !matched {
+				continue
+			}
+
+			err = RunFixtureCase(ctx, filepath.Dir(path), sp, fixtureCase)
+			if err != nil {
+				report.Fail("%s/%s: %v", path, name, err)
+				continue
+			}
+
+			report.Pass("%s/%s", path, name)
+		}
+	}
+
+	return nil
+}
+
+func ReadFixture(path string) (*Fixture, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonRaw, err := yaml.ToJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &Fixture{}
+	err = json.Unmarshal(jsonRaw, fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	return fixture, nil
+}
+
+func RunFixtureCase(ctx context.Context, dir string, sp *policy.ScalingPolicy, fixtureCase FixtureCase) error {
+	input := fixtureCase.Input
+	if fixtureCase.InputFile != "" {
+		path := fixtureCase.InputFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		document, err := ReadDocument(path)
+		if err != nil {
+			return err
+		}
+		input = document
+	}
+
+	if input == nil {
+		return fmt.Errorf("one of `input` or `inputFile` is required")
+	}
+
+	if fixtureCase.Expected == nil {
+		return fmt.Errorf("`expected` not specified")
+	}
+
+	replicas := InputTargetReplicas(sp, input)
+	if fixtureCase.Replicas != nil {
+		for name := range replicas {
+			replicas[name] = *fixtureCase.Replicas
+		}
+	}
+
+	_, decision, err := sp.Evaluate(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	if !sp.MultiTarget() {
+		scale := sp.Targets[0].Normalize(decision.Replicas, replicas[""])
+		if scale != fixtureCase.Expected.Replicas {
+			return fmt.Errorf("expected %d replicas, got %d (policy decided %s from %d)", fixtureCase.Expected.Replicas, scale, decision, replicas[""])
+		}
+		return nil
+	}
+
+	if fixtureCase.Expected.Targets == nil {
+		return fmt.Errorf("`expected` must map each target to a replica count")
+	}
+
+	scales := make(map[string]int)
+	for _, pt := range sp.Targets {
+		scales[pt.Name] = pt.Normalize(decision.TargetReplicas(pt.Name), replicas[pt.Name])
+	}
+
+	for _, pt := range sp.Targets {
+		if scales[pt.Name] != fixtureCase.Expected.Targets[pt.Name] {
+			return fmt.Errorf("expected %s, got %s (policy decided %s from %s)", policy.FormatTargetReplicas(fixtureCase.Expected.Targets), policy.FormatTargetReplicas(scales), decision, policy.FormatTargetReplicas(replicas))
+		}
+	}
+
+	return nil
+}
+
+func RunRegoTests(ctx context.Context, filter string, sp *policy.ScalingPolicy, report *TestReport) error {
+	modules := make(map[string]*ast.Module)
+	for filename, src := range sp.Modules {
+		module, err := ast.ParseModule(filename, src)
+		if err != nil {
+			return err
+		}
+		modules[filename] = module
+	}
+
+	ch, err := tester.NewRunner().
+		SetStore(inmem.NewFromObject(sp.Data)).
+		SetModules(modules).
+		Filter(filter).
+		RunTests(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	for result := range ch {
+		name := fmt.Sprintf("%s/%s.%s", sp.Name, result.Package, result.Name)
+		switch {
+		case result.Error != nil:
+			report.Fail("%s: %v", name, result.Error)
+		case result.Fail:
+			report.Fail("%s", name)
+		default:
+			report.Pass("%s", name)
+		}
+	}
+
+	return nil
+}
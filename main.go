@@ -1,9 +1,13 @@
 package main
 
 import (
+	"os"
+
 	"github.com/theMagicalKarp/agronomist/cmd"
 )
 
 func main() {
-	cmd.RootCMD().Execute()
+	if err := cmd.RootCMD().Execute(); err != nil {
+		os.Exit(1)
+	}
 }
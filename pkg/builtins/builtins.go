@@ -0,0 +1,95 @@
+package builtins
+
+import (
+	"sync"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/types"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var registerOnce sync.Once
+
+// Register makes agronomist's builtins available to every rego query. It is
+// safe to call more than once.
+func Register() {
+	registerOnce.Do(func() {
+		rego.RegisterBuiltin1(
+			&rego.Function{
+				Name: "parseunit",
+				Decl: types.NewFunction(types.Args(types.S), types.N),
+			},
+			ParseUnit,
+		)
+
+		rego.RegisterBuiltin2(
+			&rego.Function{
+				Name: "percentile",
+				Decl: types.NewFunction(types.Args(types.NewArray(nil, types.N), types.N), types.N),
+			},
+			Percentile,
+		)
+
+		rego.RegisterBuiltin1(
+			&rego.Function{
+				Name: "stddev",
+				Decl: types.NewFunction(types.Args(types.NewArray(nil, types.N)), types.N),
+			},
+			StdDev,
+		)
+
+		rego.RegisterBuiltin2(
+			&rego.Function{
+				Name: "ewma",
+				Decl: types.NewFunction(types.Args(types.NewArray(nil, types.N), types.N), types.N),
+			},
+			EWMA,
+		)
+
+		rego.RegisterBuiltin2(
+			&rego.Function{
+				Name: "hour_of_day",
+				Decl: types.NewFunction(types.Args(types.N, types.S), types.N),
+			},
+			HourOfDay,
+		)
+
+		rego.RegisterBuiltin2(
+			&rego.Function{
+				Name: "weekday",
+				Decl: types.NewFunction(types.Args(types.N, types.S), types.S),
+			},
+			Weekday,
+		)
+
+		rego.RegisterBuiltin2(
+			&rego.Function{
+				Name: "cron_match",
+				Decl: types.NewFunction(types.Args(types.S, types.N), types.B),
+			},
+			CronMatch,
+		)
+
+		rego.RegisterBuiltin1(
+			&rego.Function{
+				Name: "parseduration_seconds",
+				Decl: types.NewFunction(types.Args(types.S), types.N),
+			},
+			ParseDurationSeconds,
+		)
+	})
+}
+
+func ParseUnit(_ rego.BuiltinContext, a *ast.Term) (*ast.Term, error) {
+	if str, ok := a.Value.(ast.String); ok {
+		quantity, err := resource.ParseQuantity(string(str))
+
+		if err != nil {
+			return nil, nil
+		}
+		return ast.IntNumberTerm(int(quantity.ScaledValue(resource.Milli))), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,60 @@
+package builtins
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// TestRegister checks each builtin is callable from rego, and that empty
+// arrays leave the statistics undefined rather than failing the query.
+func TestRegister(t *testing.T) {
+	Register()
+	Register()
+
+	tests := []struct {
+		query    string
+		expected interface{}
+	}{
+		{`x := parseunit("500m")`, "500"},
+		{`x := percentile([1, 2, 3], 50)`, "2"},
+		{`x := stddev([1, 1])`, "0"},
+		{`x := ewma([10, 20], 0.5)`, "15"},
+		{`x := hour_of_day(0, "")`, "0"},
+		{`x := weekday(0, "")`, "Thursday"},
+		{`x := cron_match("* * * * *", 0)`, true},
+		{`x := parseduration_seconds("1m")`, "60"},
+		{`x := percentile([], 50)`, nil},
+		{`x := stddev([])`, nil},
+		{`x := ewma([], 0.5)`, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			rs, err := rego.New(rego.Query(test.query)).Eval(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if test.expected == nil {
+				if len(rs) != 0 {
+					t.Fatalf("expected undefined, got %v", rs)
+				}
+				return
+			}
+
+			if len(rs) != 1 {
+				t.Fatalf("expected one result, got %v", rs)
+			}
+
+			value := rs[0].Bindings["x"]
+			if number, ok := value.(interface{ String() string }); ok {
+				value = number.String()
+			}
+			if value != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, value)
+			}
+		})
+	}
+}
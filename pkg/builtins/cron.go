@@ -0,0 +1,189 @@
+package builtins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/topdown/builtins"
+)
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+// CronSchedule is a standard five field cron expression, optionally prefixed
+// with `CRON_TZ=<zone>` to match in a time zone other than UTC.
+type CronSchedule struct {
+	Location *time.Location
+	Fields   [5]uint64
+	// cron matches either day field when both are restricted
+	AnyDayOfMonth bool
+	AnyDayOfWeek  bool
+}
+
+func ParseCron(expr string) (*CronSchedule, error) {
+	schedule := &CronSchedule{Location: time.UTC}
+
+	parts := strings.Fields(expr)
+	if len(parts) > 0 && (strings.HasPrefix(parts[0], "CRON_TZ=") || strings.HasPrefix(parts[0], "TZ=")) {
+		zone := parts[0][strings.Index(parts[0], "=")+1:]
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %s", zone)
+		}
+		schedule.Location = loc
+		parts = parts[1:]
+	}
+
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(cronFields), len(parts))
+	}
+
+	for i, part := range parts {
+		bits, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		schedule.Fields[i] = bits
+	}
+
+	// 7 is an alias for sunday
+	if schedule.Fields[4]&(1<<7) != 0 {
+		schedule.Fields[4] |= 1
+	}
+
+	schedule.AnyDayOfMonth = parts[2] == "*"
+	schedule.AnyDayOfWeek = parts[4] == "*"
+
+	return schedule, nil
+}
+
+func parseCronField(expr string, field cronField) (uint64, error) {
+	var bits uint64
+
+	for _, item := range strings.Split(expr, ",") {
+		rangeExpr, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			rangeExpr = item[:i]
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid %s step in %q", field.name, item)
+			}
+			step = n
+		}
+
+		start, end := field.min, field.max
+		switch {
+		case rangeExpr == "*":
+		case strings.Contains(rangeExpr, "-"):
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err error
+			start, err = parseCronValue(bounds[0], field)
+			if err != nil {
+				return 0, err
+			}
+			end, err = parseCronValue(bounds[1], field)
+			if err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid %s range %q", field.name, rangeExpr)
+			}
+		default:
+			value, err := parseCronValue(rangeExpr, field)
+			if err != nil {
+				return 0, err
+			}
+			start = value
+			if step == 1 {
+				end = value
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(expr string, field cronField) (int, error) {
+	if value, ok := field.names[strings.ToLower(expr)]; ok {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(expr)
+	if err != nil || value < field.min || value > field.max {
+		return 0, fmt.Errorf("invalid %s %q", field.name, expr)
+	}
+
+	return value, nil
+}
+
+func (c *CronSchedule) Match(t time.Time) bool {
+	t = t.In(c.Location)
+
+	if c.Fields[0]&(1<<uint(t.Minute())) == 0 {
+		return false
+	}
+	if c.Fields[1]&(1<<uint(t.Hour())) == 0 {
+		return false
+	}
+	if c.Fields[3]&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dayOfMonth := c.Fields[2]&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.Fields[4]&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case c.AnyDayOfMonth && c.AnyDayOfWeek:
+		return true
+	case c.AnyDayOfMonth:
+		return dayOfWeek
+	case c.AnyDayOfWeek:
+		return dayOfMonth
+	}
+
+	return dayOfMonth || dayOfWeek
+}
+
+func CronMatch(_ rego.BuiltinContext, a, b *ast.Term) (*ast.Term, error) {
+	expr, err := builtins.StringOperand(a.Value, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := ParseCron(string(expr))
+	if err != nil {
+		return nil, builtins.NewOperandErr(1, "invalid cron expression: %v", err)
+	}
+
+	t, err := TimeOperand(b, ast.StringTerm("UTC"), 2)
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.BooleanTerm(schedule.Match(t)), nil
+}
@@ -0,0 +1,67 @@
+package builtins
+
+import (
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+func TestCronMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		ns       *ast.Term
+		expected bool
+		wantErr  bool
+	}{
+		{"every minute", "* * * * *", nanos("2021-06-01T13:45:00Z"), true, false},
+		{"exact minute", "45 13 * * *", nanos("2021-06-01T13:45:00Z"), true, false},
+		{"other minute", "44 13 * * *", nanos("2021-06-01T13:45:00Z"), false, false},
+		{"hour range", "* 9-17 * * *", nanos("2021-06-01T17:59:00Z"), true, false},
+		{"outside hour range", "* 9-17 * * *", nanos("2021-06-01T18:00:00Z"), false, false},
+		{"step", "*/15 * * * *", nanos("2021-06-01T13:45:00Z"), true, false},
+		{"off step", "*/15 * * * *", nanos("2021-06-01T13:46:00Z"), false, false},
+		{"list", "0,30 * * * *", nanos("2021-06-01T13:30:00Z"), true, false},
+		{"weekday names", "* * * * mon-fri", nanos("2021-06-01T13:45:00Z"), true, false},
+		{"weekend", "* * * * sat,sun", nanos("2021-06-01T13:45:00Z"), false, false},
+		{"7 is sunday", "* * * * 7", nanos("2021-06-06T13:45:00Z"), true, false},
+		{"month names", "* * * jun *", nanos("2021-06-01T13:45:00Z"), true, false},
+		// both day fields restricted matches either of them
+		{"day of month or week", "* * 15 * mon", nanos("2021-06-15T13:45:00Z"), true, false},
+		{"neither day", "* * 15 * mon", nanos("2021-06-16T13:45:00Z"), false, false},
+		{"time zone", "CRON_TZ=America/New_York * 9 * * *", nanos("2021-06-01T13:45:00Z"), true, false},
+		{"TZ alias", "TZ=America/New_York * 9 * * *", nanos("2021-06-01T13:45:00Z"), true, false},
+		// 02:30 doesn't exist in Berlin on the day clocks spring forward
+		{"skipped by spring forward", "CRON_TZ=Europe/Berlin 30 2 * * *", nanos("2021-03-28T00:30:00Z"), false, false},
+		{"after spring forward", "CRON_TZ=Europe/Berlin 30 3 * * *", nanos("2021-03-28T01:30:00Z"), true, false},
+		// 02:30 happens twice in Berlin on the day clocks fall back
+		{"first 02:30 at fall back", "CRON_TZ=Europe/Berlin 30 2 * * *", nanos("2021-10-31T00:30:00Z"), true, false},
+		{"second 02:30 at fall back", "CRON_TZ=Europe/Berlin 30 2 * * *", nanos("2021-10-31T01:30:00Z"), true, false},
+		{"too few fields", "* * * *", nanos("2021-06-01T13:45:00Z"), false, true},
+		{"too many fields", "* * * * * *", nanos("2021-06-01T13:45:00Z"), false, true},
+		{"minute out of range", "60 * * * *", nanos("2021-06-01T13:45:00Z"), false, true},
+		{"zero step", "*/0 * * * *", nanos("2021-06-01T13:45:00Z"), false, true},
+		{"backwards range", "* 17-9 * * *", nanos("2021-06-01T13:45:00Z"), false, true},
+		{"unknown name", "* * * * funday", nanos("2021-06-01T13:45:00Z"), false, true},
+		{"invalid time zone", "CRON_TZ=Nowhere * * * * *", nanos("2021-06-01T13:45:00Z"), false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := CronMatch(rego.BuiltinContext{}, ast.StringTerm(test.expr), test.ns)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Equal(ast.BooleanTerm(test.expected)) {
+				t.Fatalf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
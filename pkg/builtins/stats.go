@@ -0,0 +1,128 @@
+package builtins
+
+import (
+	"math"
+	"sort"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/topdown/builtins"
+)
+
+func NumbersOperand(term *ast.Term, pos int) ([]float64, error) {
+	array, ok := term.Value.(ast.Array)
+	if !ok {
+		return nil, builtins.NewOperandTypeErr(pos, term.Value, "array")
+	}
+
+	numbers := make([]float64, 0, len(array))
+	for _, item := range array {
+		number, err := NumberOperand(item, pos)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, number)
+	}
+
+	return numbers, nil
+}
+
+func NumberOperand(term *ast.Term, pos int) (float64, error) {
+	number, ok := term.Value.(ast.Number)
+	if !ok {
+		return 0, builtins.NewOperandTypeErr(pos, term.Value, "number")
+	}
+
+	f, ok := number.Float64()
+	if !ok {
+		return 0, builtins.NewOperandErr(pos, "number %v out of range", number)
+	}
+
+	return f, nil
+}
+
+// Percentile returns the p-th percentile (0-100) of values, linearly
+// interpolating between the closest ranks.
+func Percentile(_ rego.BuiltinContext, a, b *ast.Term) (*ast.Term, error) {
+	values, err := NumbersOperand(a, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := NumberOperand(b, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	if p < 0 || p > 100 {
+		return nil, builtins.NewOperandErr(2, "percentile must be between 0 and 100")
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	sort.Float64s(values)
+
+	rank := p / 100 * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	result := values[lower] + (values[upper]-values[lower])*(rank-float64(lower))
+	return ast.FloatNumberTerm(result), nil
+}
+
+// StdDev returns the population standard deviation of values.
+func StdDev(_ rego.BuiltinContext, a *ast.Term) (*ast.Term, error) {
+	values, err := NumbersOperand(a, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	var mean float64
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+
+	var variance float64
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	variance /= float64(len(values))
+
+	return ast.FloatNumberTerm(math.Sqrt(variance)), nil
+}
+
+// EWMA returns the exponentially weighted moving average of values, oldest
+// first, where alpha (0-1] is the weight given to each new value.
+func EWMA(_ rego.BuiltinContext, a, b *ast.Term) (*ast.Term, error) {
+	values, err := NumbersOperand(a, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	alpha, err := NumberOperand(b, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	if alpha <= 0 || alpha > 1 {
+		return nil, builtins.NewOperandErr(2, "alpha must be greater than 0 and at most 1")
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	average := values[0]
+	for _, value := range values[1:] {
+		average = alpha*value + (1-alpha)*average
+	}
+
+	return ast.FloatNumberTerm(average), nil
+}
@@ -0,0 +1,131 @@
+package builtins
+
+import (
+	"math"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+func numbers(values ...float64) *ast.Term {
+	var terms []*ast.Term
+	for _, value := range values {
+		terms = append(terms, ast.FloatNumberTerm(value))
+	}
+	return ast.ArrayTerm(terms...)
+}
+
+// checkNumber compares a builtin result with the expected number, where nil
+// means the result should be undefined.
+func checkNumber(t *testing.T, result *ast.Term, err error, expected *float64, wantErr bool) {
+	t.Helper()
+
+	if wantErr {
+		if err == nil {
+			t.Fatalf("expected an error, got %v", result)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected == nil {
+		if result != nil {
+			t.Fatalf("expected undefined, got %v", result)
+		}
+		return
+	}
+
+	if result == nil {
+		t.Fatalf("expected %v, got undefined", *expected)
+	}
+	value, ok := result.Value.(ast.Number).Float64()
+	if !ok {
+		t.Fatalf("result %v is not a float", result)
+	}
+	if math.Abs(value-*expected) > 1e-9 {
+		t.Fatalf("expected %v, got %v", *expected, value)
+	}
+}
+
+func float(value float64) *float64 {
+	return &value
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   *ast.Term
+		p        *ast.Term
+		expected *float64
+		wantErr  bool
+	}{
+		{"median of odd count", numbers(3, 1, 2), ast.IntNumberTerm(50), float(2), false},
+		{"interpolates between ranks", numbers(1, 2, 3, 4), ast.IntNumberTerm(50), float(2.5), false},
+		{"90th", numbers(10, 20, 30, 40, 50, 60, 70, 80, 90, 100), ast.IntNumberTerm(90), float(91), false},
+		{"0th is the minimum", numbers(5, 1, 9), ast.IntNumberTerm(0), float(1), false},
+		{"100th is the maximum", numbers(5, 1, 9), ast.IntNumberTerm(100), float(9), false},
+		{"single value", numbers(7), ast.IntNumberTerm(42), float(7), false},
+		{"empty array is undefined", numbers(), ast.IntNumberTerm(50), nil, false},
+		{"below 0", numbers(1, 2), ast.IntNumberTerm(-1), nil, true},
+		{"above 100", numbers(1, 2), ast.IntNumberTerm(101), nil, true},
+		{"not an array", ast.StringTerm("1,2"), ast.IntNumberTerm(50), nil, true},
+		{"non numeric value", ast.ArrayTerm(ast.StringTerm("a")), ast.IntNumberTerm(50), nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := Percentile(rego.BuiltinContext{}, test.values, test.p)
+			checkNumber(t, result, err, test.expected, test.wantErr)
+		})
+	}
+}
+
+func TestStdDev(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   *ast.Term
+		expected *float64
+		wantErr  bool
+	}{
+		{"population deviation", numbers(2, 4, 4, 4, 5, 5, 7, 9), float(2), false},
+		{"constant values", numbers(3, 3, 3), float(0), false},
+		{"single value", numbers(42), float(0), false},
+		{"empty array is undefined", numbers(), nil, false},
+		{"not an array", ast.IntNumberTerm(1), nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := StdDev(rego.BuiltinContext{}, test.values)
+			checkNumber(t, result, err, test.expected, test.wantErr)
+		})
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   *ast.Term
+		alpha    *ast.Term
+		expected *float64
+		wantErr  bool
+	}{
+		{"weights newer values", numbers(10, 20), ast.FloatNumberTerm(0.5), float(15), false},
+		{"three values", numbers(0, 10, 20), ast.FloatNumberTerm(0.5), float(12.5), false},
+		{"alpha of 1 is the last value", numbers(1, 2, 3), ast.IntNumberTerm(1), float(3), false},
+		{"single value", numbers(4), ast.FloatNumberTerm(0.3), float(4), false},
+		{"empty array is undefined", numbers(), ast.FloatNumberTerm(0.5), nil, false},
+		{"alpha of 0", numbers(1, 2), ast.IntNumberTerm(0), nil, true},
+		{"alpha above 1", numbers(1, 2), ast.FloatNumberTerm(1.5), nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := EWMA(rego.BuiltinContext{}, test.values, test.alpha)
+			checkNumber(t, result, err, test.expected, test.wantErr)
+		})
+	}
+}
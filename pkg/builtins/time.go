@@ -0,0 +1,67 @@
+package builtins
+
+import (
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/topdown/builtins"
+)
+
+// TimeOperand converts nanoseconds since the epoch, as returned by
+// time.now_ns, into a time in the named location. An empty location is UTC.
+func TimeOperand(ns, location *ast.Term, pos int) (time.Time, error) {
+	number, ok := ns.Value.(ast.Number)
+	if !ok {
+		return time.Time{}, builtins.NewOperandTypeErr(pos, ns.Value, "number")
+	}
+
+	nanos, ok := number.Int64()
+	if !ok {
+		return time.Time{}, builtins.NewOperandErr(pos, "timestamp must be an integer number of nanoseconds")
+	}
+
+	name, err := builtins.StringOperand(location.Value, pos+1)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc, err := time.LoadLocation(string(name))
+	if err != nil {
+		return time.Time{}, builtins.NewOperandErr(pos+1, "unknown time zone %s", name)
+	}
+
+	return time.Unix(0, nanos).In(loc), nil
+}
+
+func HourOfDay(_ rego.BuiltinContext, a, b *ast.Term) (*ast.Term, error) {
+	t, err := TimeOperand(a, b, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.IntNumberTerm(t.Hour()), nil
+}
+
+func Weekday(_ rego.BuiltinContext, a, b *ast.Term) (*ast.Term, error) {
+	t, err := TimeOperand(a, b, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.StringTerm(t.Weekday().String()), nil
+}
+
+func ParseDurationSeconds(_ rego.BuiltinContext, a *ast.Term) (*ast.Term, error) {
+	str, err := builtins.StringOperand(a.Value, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := time.ParseDuration(string(str))
+	if err != nil {
+		return nil, builtins.NewOperandErr(1, "invalid duration %s", str)
+	}
+
+	return ast.FloatNumberTerm(duration.Seconds()), nil
+}
@@ -0,0 +1,119 @@
+package builtins
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+func nanos(value string) *ast.Term {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return ast.IntNumberTerm(int(t.UnixNano()))
+}
+
+func TestHourOfDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		ns       *ast.Term
+		tz       string
+		expected int
+		wantErr  bool
+	}{
+		{"utc", nanos("2021-06-01T13:45:00Z"), "", 13, false},
+		{"explicit utc", nanos("2021-06-01T13:45:00Z"), "UTC", 13, false},
+		{"summer time", nanos("2021-06-01T13:45:00Z"), "Europe/Berlin", 15, false},
+		{"winter time", nanos("2021-01-01T13:45:00Z"), "Europe/Berlin", 14, false},
+		// clocks jump from 02:00 to 03:00 local
+		{"before spring forward", nanos("2021-03-28T00:59:59Z"), "Europe/Berlin", 1, false},
+		{"after spring forward", nanos("2021-03-28T01:00:00Z"), "Europe/Berlin", 3, false},
+		// clocks fall back from 03:00 to 02:00 local, so 2am happens twice
+		{"first 2am", nanos("2021-10-31T00:30:00Z"), "Europe/Berlin", 2, false},
+		{"second 2am", nanos("2021-10-31T01:30:00Z"), "Europe/Berlin", 2, false},
+		{"invalid time zone", nanos("2021-06-01T13:45:00Z"), "Mars/Olympus_Mons", 0, true},
+		{"fractional timestamp", ast.FloatNumberTerm(1.5), "", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := HourOfDay(rego.BuiltinContext{}, test.ns, ast.StringTerm(test.tz))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Equal(ast.IntNumberTerm(test.expected)) {
+				t.Fatalf("expected %d, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestWeekday(t *testing.T) {
+	tests := []struct {
+		name     string
+		ns       *ast.Term
+		tz       string
+		expected string
+		wantErr  bool
+	}{
+		{"utc", nanos("2021-06-01T12:00:00Z"), "", "Tuesday", false},
+		{"ahead of utc", nanos("2021-06-01T23:30:00Z"), "Asia/Tokyo", "Wednesday", false},
+		{"behind utc", nanos("2021-06-01T02:00:00Z"), "America/New_York", "Monday", false},
+		{"across fall back", nanos("2021-11-07T05:30:00Z"), "America/New_York", "Sunday", false},
+		{"invalid time zone", nanos("2021-06-01T12:00:00Z"), "Nowhere", "", true},
+		{"not a number", ast.StringTerm("now"), "", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := Weekday(rego.BuiltinContext{}, test.ns, ast.StringTerm(test.tz))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Equal(ast.StringTerm(test.expected)) {
+				t.Fatalf("expected %s, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseDurationSeconds(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    *ast.Term
+		expected *float64
+		wantErr  bool
+	}{
+		{"minutes and seconds", ast.StringTerm("1m30s"), float(90), false},
+		{"hours", ast.StringTerm("2h"), float(7200), false},
+		{"fractional", ast.StringTerm("1.5s"), float(1.5), false},
+		{"milliseconds", ast.StringTerm("250ms"), float(0.25), false},
+		{"negative", ast.StringTerm("-5s"), float(-5), false},
+		{"zero", ast.StringTerm("0"), float(0), false},
+		{"missing unit", ast.StringTerm("5"), nil, true},
+		{"garbage", ast.StringTerm("soon"), nil, true},
+		{"not a string", ast.IntNumberTerm(5), nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := ParseDurationSeconds(rego.BuiltinContext{}, test.value)
+			checkNumber(t, result, err, test.expected, test.wantErr)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Decision struct {
+	Replicas   int               `json:"replicas"`
+	Targets    map[string]int    `json:"targets,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+	Confidence float64           `json:"confidence,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+
+	State map[string]interface{} `json:"state,omitempty"`
+}
+
+type decisionDocument struct {
+	Replicas   json.RawMessage        `json:"replicas"`
+	Reason     string                 `json:"reason"`
+	Confidence *json.Number           `json:"confidence"`
+	Labels     map[string]string      `json:"labels"`
+	State      map[string]interface{} `json:"state"`
+}
+
+// ParseDecision accepts a replica count, a map of target name to replica
+// count, or a decision document whose `replicas` is either of those.
+func ParseDecision(value interface{}) (*Decision, error) {
+	switch v := value.(type) {
+	case json.Number:
+		replicas, err := v.Int64()
+		if err != nil {
+			return nil, err
+		}
+		return &Decision{Replicas: int(replicas)}, nil
+	case map[string]interface{}:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, exists := v["replicas"]; !exists {
+			targets, err := ParseTargetReplicas(raw)
+			if err != nil {
+				return nil, fmt.Errorf("decision document missing `replicas`")
+			}
+			return &Decision{Targets: targets}, nil
+		}
+
+		var doc decisionDocument
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("invalid decision document: %v", err)
+		}
+
+		decision := &Decision{
+			Reason: doc.Reason,
+			Labels: doc.Labels,
+			State:  doc.State,
+		}
+
+		var replicas json.Number
+		if err := json.Unmarshal(doc.Replicas, &replicas); err == nil {
+			n, err := replicas.Int64()
+			if err != nil {
+				return nil, err
+			}
+			decision.Replicas = int(n)
+		} else {
+			decision.Targets, err = ParseTargetReplicas(doc.Replicas)
+			if err != nil {
+				return nil, fmt.Errorf("invalid decision document: `replicas` must be a number or a map of target name to number")
+			}
+		}
+
+		if doc.Confidence != nil {
+			confidence, err := doc.Confidence.Float64()
+			if err != nil {
+				return nil, err
+			}
+			decision.Confidence = confidence
+		}
+
+		return decision, nil
+	}
+
+	return nil, fmt.Errorf("INCORRECT RESPONSE TYPE %T", value)
+}
+
+func ParseTargetReplicas(raw []byte) (map[string]int, error) {
+	var numbers map[string]json.Number
+	err := json.Unmarshal(raw, &numbers)
+	if err != nil {
+		return nil, err
+	}
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("no targets")
+	}
+
+	targets := make(map[string]int)
+	for name, number := range numbers {
+		replicas, err := number.Int64()
+		if err != nil {
+			return nil, err
+		}
+		targets[name] = int(replicas)
+	}
+
+	return targets, nil
+}
+
+// TargetReplicas returns the replicas decided for a target, the unnamed
+// target of a single target policy being the plain replica count.
+func (d *Decision) TargetReplicas(name string) int {
+	if d.Targets == nil {
+		return d.Replicas
+	}
+	return d.Targets[name]
+}
+
+func (d *Decision) String() string {
+	replicas := fmt.Sprintf("%d", d.Replicas)
+	if d.Targets != nil {
+		replicas = FormatTargetReplicas(d.Targets)
+	}
+
+	if d.Reason == "" {
+		return replicas
+	}
+	return fmt.Sprintf("%s (%s)", replicas, d.Reason)
+}
+
+// FormatTargetReplicas prints replica counts by target name, sorted by name.
+func FormatTargetReplicas(targets map[string]int) string {
+	var names []string
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, targets[name]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,124 @@
+package policy
+
+import (
+	"sync"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// HistoryEntry is a single metrics sample aggregated across every pod of the
+// deployment. Usage values are totals in milli units, matching parseunit.
+type HistoryEntry struct {
+	Timestamp int64            `json:"timestamp"`
+	Pods      int              `json:"pods"`
+	Usage     map[string]int64 `json:"usage"`
+	Average   map[string]int64 `json:"average"`
+}
+
+// History is a fixed size ring buffer of the most recent samples.
+type History struct {
+	Size    int
+	Entries []HistoryEntry
+	Next    int
+	Mutex   sync.Mutex
+}
+
+func CreateHistory(size int) *History {
+	if size < 0 {
+		size = 0
+	}
+	return &History{
+		Size:    size,
+		Entries: make([]HistoryEntry, 0, size),
+	}
+}
+
+func CreateHistoryEntry(now time.Time, podMetrics []*metricsv1beta1.PodMetrics) HistoryEntry {
+	usage := make(map[string]int64)
+	for _, podMetric := range podMetrics {
+		for _, container := range podMetric.Containers {
+			for name, quantity := range container.Usage {
+				usage[string(name)] += quantity.MilliValue()
+			}
+		}
+	}
+
+	for _, name := range []coreV1.ResourceName{coreV1.ResourceCPU, coreV1.ResourceMemory} {
+		if _, exists := usage[string(name)]; !exists {
+			usage[string(name)] = 0
+		}
+	}
+
+	average := make(map[string]int64)
+	for name, total := range usage {
+		if len(podMetrics) > 0 {
+			average[name] = total / int64(len(podMetrics))
+		} else {
+			average[name] = 0
+		}
+	}
+
+	return HistoryEntry{
+		Timestamp: now.UnixNano(),
+		Pods:      len(podMetrics),
+		Usage:     usage,
+		Average:   average,
+	}
+}
+
+func (h *History) Add(entry HistoryEntry) {
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+
+	if h.Size == 0 {
+		return
+	}
+
+	if len(h.Entries) < h.Size {
+		h.Entries = append(h.Entries, entry)
+		return
+	}
+
+	h.Entries[h.Next] = entry
+	h.Next = (h.Next + 1) % h.Size
+}
+
+// List returns the buffered samples oldest first.
+func (h *History) List() []HistoryEntry {
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+
+	result := make([]HistoryEntry, 0, len(h.Entries))
+	result = append(result, h.Entries[h.Next:]...)
+	result = append(result, h.Entries[:h.Next]...)
+	return result
+}
+
+// Resize changes the window, keeping the newest samples that still fit.
+func (h *History) Resize(size int) {
+	entries := h.List()
+
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+
+	if size < 0 {
+		size = 0
+	}
+	if len(entries) > size {
+		entries = entries[len(entries)-size:]
+	}
+
+	h.Size = size
+	h.Entries = append(make([]HistoryEntry, 0, size), entries...)
+	h.Next = 0
+}
+
+func (h *History) Clear() {
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+
+	h.Entries = make([]HistoryEntry, 0, h.Size)
+	h.Next = 0
+}
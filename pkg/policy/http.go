@@ -0,0 +1,256 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const MaxHTTPResponseSize = 4 * 1024 * 1024
+
+type HTTPHeader struct {
+	Name      string
+	Value     string
+	SecretRef *SecretKeyRef
+}
+
+type SecretKeyRef struct {
+	Name string
+	Key  string
+}
+
+type HTTPInput struct {
+	Name     string
+	URL      string
+	Method   string
+	Headers  []HTTPHeader
+	Timeout  time.Duration
+	CacheTTL time.Duration
+}
+
+type HTTPCacheEntry struct {
+	Value   map[string]interface{}
+	Expires time.Time
+}
+
+// HTTPCache holds successful responses of a policy's http inputs until their
+// ttl expires.
+type HTTPCache struct {
+	Client  *http.Client
+	Entries map[string]*HTTPCacheEntry
+	Mutex   sync.Mutex
+}
+
+func CreateHTTPCache(client *http.Client) *HTTPCache {
+	return &HTTPCache{
+		Client:  client,
+		Entries: make(map[string]*HTTPCacheEntry),
+	}
+}
+
+func NestedHTTPInputs(obj *unstructured.Unstructured) ([]HTTPInput, error) {
+	items, _, err := unstructured.NestedSlice(obj.Object, "spec", "inputs", "http")
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []HTTPInput
+	names := make(map[string]bool)
+	for i, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("`spec.inputs.http[%d]` must be an object", i)
+		}
+
+		input := HTTPInput{}
+		for _, key := range []string{"name", "url"} {
+			value, _, err := unstructured.NestedString(entry, key)
+			if err != nil {
+				return nil, err
+			}
+			if value == "" {
+				return nil, fmt.Errorf("`spec.inputs.http[%d].%s` not specified", i, key)
+			}
+		}
+		input.Name = entry["name"].(string)
+		input.URL = entry["url"].(string)
+
+		if names[input.Name] {
+			return nil, fmt.Errorf("duplicate http input `%s`", input.Name)
+		}
+		names[input.Name] = true
+
+		input.Method, _, err = unstructured.NestedString(entry, "method")
+		if err != nil {
+			return nil, err
+		}
+		if input.Method == "" {
+			input.Method = "GET"
+		}
+
+		input.Timeout, err = NestedTimeout(entry, "timeout")
+		if err != nil {
+			return nil, err
+		}
+
+		cacheTTL, _, err := unstructured.NestedInt64(entry, "cacheTTL")
+		if err != nil {
+			return nil, err
+		}
+		input.CacheTTL = time.Duration(cacheTTL) * time.Second
+
+		headers, _, err := unstructured.NestedSlice(entry, "headers")
+		if err != nil {
+			return nil, err
+		}
+		for j, raw := range headers {
+			header, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("`spec.inputs.http[%d].headers[%d]` must be an object", i, j)
+			}
+
+			name, _, _ := unstructured.NestedString(header, "name")
+			if name == "" {
+				return nil, fmt.Errorf("`spec.inputs.http[%d].headers[%d].name` not specified", i, j)
+			}
+			value, _, _ := unstructured.NestedString(header, "value")
+
+			var secretRef *SecretKeyRef
+			secretName, exists, _ := unstructured.NestedString(header, "valueFrom", "secretKeyRef", "name")
+			if exists {
+				key, _, _ := unstructured.NestedString(header, "valueFrom", "secretKeyRef", "key")
+				if secretName == "" || key == "" {
+					return nil, fmt.Errorf("`spec.inputs.http[%d].headers[%d].valueFrom.secretKeyRef` requires a name and key", i, j)
+				}
+				secretRef = &SecretKeyRef{Name: secretName, Key: key}
+			}
+
+			input.Headers = append(input.Headers, HTTPHeader{Name: name, Value: value, SecretRef: secretRef})
+		}
+
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
+
+func (h HTTPInput) Request(ctx context.Context, namespace string, secrets SecretResolver) (*http.Request, error) {
+	request, err := http.NewRequest(h.Method, h.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Accept", "application/json")
+
+	for _, header := range h.Headers {
+		value := header.Value
+		if header.SecretRef != nil {
+			secret, exists, err := secrets.GetSecret(ctx, namespace, header.SecretRef.Name)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, fmt.Errorf("secret %s/%s not found", namespace, header.SecretRef.Name)
+			}
+			data, exists := secret.Data[header.SecretRef.Key]
+			if !exists {
+				return nil, fmt.Errorf("secret %s/%s has no key `%s`", namespace, header.SecretRef.Name, header.SecretRef.Key)
+			}
+			value = string(data)
+		}
+		request.Header.Set(header.Name, value)
+	}
+
+	return request, nil
+}
+
+// Fetch calls the endpoint and returns {status, body} on success or
+// {status, error} when the request fails, so one broken endpoint does not
+// abort the evaluation.
+func (h HTTPInput) Fetch(ctx context.Context, client *http.Client, namespace string, secrets SecretResolver) (map[string]interface{}, bool) {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	failure := func(status int, err error) (map[string]interface{}, bool) {
+		return map[string]interface{}{
+			"status": status,
+			"error": map[string]interface{}{
+				"message": err.Error(),
+			},
+		}, false
+	}
+
+	request, err := h.Request(ctx, namespace, secrets)
+	if err != nil {
+		return failure(0, err)
+	}
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return failure(0, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxHTTPResponseSize+1))
+	if err != nil {
+		return failure(resp.StatusCode, err)
+	}
+	if len(raw) > MaxHTTPResponseSize {
+		return failure(resp.StatusCode, fmt.Errorf("response larger than %d bytes", MaxHTTPResponseSize))
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return failure(resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status))
+	}
+
+	var body interface{}
+	err = json.Unmarshal(raw, &body)
+	if err != nil {
+		return failure(resp.StatusCode, fmt.Errorf("invalid json response: %v", err))
+	}
+
+	return map[string]interface{}{
+		"status": resp.StatusCode,
+		"body":   body,
+	}, true
+}
+
+// FetchHTTP calls every http input of the policy, keyed by name. Successful
+// responses are reused until their cacheTTL expires.
+func (s *ScalingPolicy) FetchHTTP(ctx context.Context, now time.Time, secrets SecretResolver) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, input := range s.HTTP {
+		s.HTTPCache.Mutex.Lock()
+		entry := s.HTTPCache.Entries[input.Name]
+		s.HTTPCache.Mutex.Unlock()
+
+		if entry != nil && now.Before(entry.Expires) {
+			result[input.Name] = entry.Value
+			continue
+		}
+
+		value, ok := input.Fetch(ctx, s.HTTPCache.Client, s.Namespace, secrets)
+		if !ok {
+			fmt.Printf("%s http input `%s` failed: %v\n", s.Name, input.Name, value["error"].(map[string]interface{})["message"])
+		}
+
+		if ok && input.CacheTTL > 0 {
+			s.HTTPCache.Mutex.Lock()
+			s.HTTPCache.Entries[input.Name] = &HTTPCacheEntry{
+				Value:   value,
+				Expires: now.Add(input.CacheTTL),
+			}
+			s.HTTPCache.Mutex.Unlock()
+		}
+
+		result[input.Name] = value
+	}
+	return result
+}
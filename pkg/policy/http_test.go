@@ -0,0 +1,222 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchHTTP(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		delay    time.Duration
+		expected map[string]interface{}
+		err      string
+	}{
+		{
+			name:   "json body",
+			status: http.StatusOK,
+			body:   `{"depth": 42, "queues": ["a", "b"]}`,
+			expected: map[string]interface{}{
+				"status": http.StatusOK,
+				"body": map[string]interface{}{
+					"depth":  float64(42),
+					"queues": []interface{}{"a", "b"},
+				},
+			},
+		},
+		{
+			name:   "other 2xx status",
+			status: http.StatusAccepted,
+			body:   `[]`,
+			expected: map[string]interface{}{
+				"status": http.StatusAccepted,
+				"body":   []interface{}{},
+			},
+		},
+		{
+			name:   "server error",
+			status: http.StatusServiceUnavailable,
+			body:   `{"depth": 42}`,
+			err:    "unexpected status 503 Service Unavailable",
+		},
+		{
+			name:   "invalid json",
+			status: http.StatusOK,
+			body:   `depth=42`,
+			err:    "invalid json response",
+		},
+		{
+			name:   "timeout",
+			status: http.StatusOK,
+			body:   `{}`,
+			delay:  time.Second,
+			err:    "context deadline exceeded",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.delay > 0 {
+					select {
+					case <-time.After(test.delay):
+					case <-r.Context().Done():
+						return
+					}
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			sp := &ScalingPolicy{
+				Name:      "foo",
+				Namespace: "apps",
+				HTTP: []HTTPInput{
+					{Name: "queue", URL: server.URL, Method: "GET", Timeout: 100 * time.Millisecond},
+				},
+				HTTPCache: CreateHTTPCache(server.Client()),
+			}
+
+			result := sp.FetchHTTP(context.Background(), time.Now(), fakeSecrets{})
+			value, ok := result["queue"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("expected a result for `queue`, got %v", result)
+			}
+
+			if test.err == "" {
+				if !reflect.DeepEqual(value, test.expected) {
+					t.Fatalf("expected %#v, got %#v", test.expected, value)
+				}
+				return
+			}
+
+			status := test.status
+			if test.delay > 0 {
+				status = 0
+			}
+			if value["status"] != status {
+				t.Fatalf("expected status %d, got %v", status, value["status"])
+			}
+			message, _ := value["error"].(map[string]interface{})["message"].(string)
+			if !strings.Contains(message, test.err) {
+				t.Fatalf("expected error containing %q, got %q", test.err, message)
+			}
+			if _, exists := value["body"]; exists {
+				t.Fatalf("expected no body on failure, got %v", value["body"])
+			}
+		})
+	}
+}
+
+func TestFetchHTTPHeaders(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	secrets := fakeSecrets{
+		"apps/api": createSecret("apps", "api", map[string]string{"key": "s3cret"}),
+	}
+
+	sp := &ScalingPolicy{
+		Name:      "foo",
+		Namespace: "apps",
+		HTTP: []HTTPInput{
+			{
+				Name:    "queue",
+				URL:     server.URL,
+				Method:  "GET",
+				Timeout: time.Second,
+				Headers: []HTTPHeader{
+					{Name: "X-Tenant", Value: "blue"},
+					{Name: "X-Api-Key", SecretRef: &SecretKeyRef{Name: "api", Key: "key"}},
+				},
+			},
+		},
+		HTTPCache: CreateHTTPCache(server.Client()),
+	}
+
+	result := sp.FetchHTTP(context.Background(), time.Now(), secrets)
+	if status := result["queue"].(map[string]interface{})["status"]; status != http.StatusOK {
+		t.Fatalf("expected status 200, got %v", result["queue"])
+	}
+
+	for name, expected := range map[string]string{
+		"Accept":    "application/json",
+		"X-Tenant":  "blue",
+		"X-Api-Key": "s3cret",
+	} {
+		if headers.Get(name) != expected {
+			t.Fatalf("expected header %s to be %q, got %q", name, expected, headers.Get(name))
+		}
+	}
+}
+
+func TestFetchHTTPCache(t *testing.T) {
+	var calls int32
+	var failing int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"call": %d}`, call)
+	}))
+	defer server.Close()
+
+	sp := &ScalingPolicy{
+		Name:      "foo",
+		Namespace: "apps",
+		HTTP: []HTTPInput{
+			{Name: "cached", URL: server.URL, Method: "GET", Timeout: time.Second, CacheTTL: time.Minute},
+		},
+		HTTPCache: CreateHTTPCache(server.Client()),
+	}
+
+	body := func(result map[string]interface{}) interface{} {
+		return result["cached"].(map[string]interface{})["body"]
+	}
+
+	start := time.Now()
+	steps := []struct {
+		name    string
+		now     time.Time
+		failing bool
+		calls   int32
+		body    interface{}
+	}{
+		{"first fetch", start, false, 1, map[string]interface{}{"call": float64(1)}},
+		{"cache hit", start.Add(30 * time.Second), false, 1, map[string]interface{}{"call": float64(1)}},
+		{"expired", start.Add(time.Minute), false, 2, map[string]interface{}{"call": float64(2)}},
+		{"cache hit after refresh", start.Add(90 * time.Second), false, 2, map[string]interface{}{"call": float64(2)}},
+		{"failure after expiry", start.Add(3 * time.Minute), true, 3, nil},
+		{"failures aren't cached", start.Add(3*time.Minute + time.Second), true, 4, nil},
+	}
+
+	for _, step := range steps {
+		if step.failing {
+			atomic.StoreInt32(&failing, 1)
+		}
+
+		result := sp.FetchHTTP(context.Background(), step.now, fakeSecrets{})
+		if got := atomic.LoadInt32(&calls); got != step.calls {
+			t.Fatalf("%s: expected %d calls, got %d", step.name, step.calls, got)
+		}
+		if !reflect.DeepEqual(body(result), step.body) {
+			t.Fatalf("%s: expected body %v, got %v", step.name, step.body, body(result))
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/theMagicalKarp/agronomist/pkg/prometheus"
+)
+
+const DefaultInputTimeout = 10 * time.Second
+
+type SecretResolver interface {
+	GetSecret(ctx context.Context, namespace, name string) (*coreV1.Secret, bool, error)
+}
+
+type SecretRef struct {
+	Name        string
+	TokenKey    string
+	UsernameKey string
+	PasswordKey string
+}
+
+type PrometheusInput struct {
+	Name      string
+	Address   string
+	Query     string
+	Timeout   time.Duration
+	SecretRef *SecretRef
+}
+
+func NestedSecretRef(obj map[string]interface{}, fields ...string) (*SecretRef, error) {
+	_, exists, err := unstructured.NestedMap(obj, fields...)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	ref := &SecretRef{
+		TokenKey:    "token",
+		UsernameKey: "username",
+		PasswordKey: "password",
+	}
+
+	for _, field := range []struct {
+		key   string
+		value *string
+	}{
+		{"name", &ref.Name},
+		{"tokenKey", &ref.TokenKey},
+		{"usernameKey", &ref.UsernameKey},
+		{"passwordKey", &ref.PasswordKey},
+	} {
+		value, exists, err := unstructured.NestedString(obj, append(fields, field.key)...)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			*field.value = value
+		}
+	}
+
+	if ref.Name == "" {
+		return nil, fmt.Errorf("`secretRef.name` not specified")
+	}
+
+	return ref, nil
+}
+
+// Credentials reads a bearer token, or failing that a username and password,
+// from the referenced secret in the policy namespace.
+func (r *SecretRef) Credentials(ctx context.Context, namespace string, secrets SecretResolver) (string, string, string, error) {
+	secret, exists, err := secrets.GetSecret(ctx, namespace, r.Name)
+	if err != nil {
+		return "", "", "", err
+	}
+	if !exists {
+		return "", "", "", fmt.Errorf("secret %s/%s not found", namespace, r.Name)
+	}
+
+	if token, exists := secret.Data[r.TokenKey]; exists {
+		return string(token), "", "", nil
+	}
+
+	username, exists := secret.Data[r.UsernameKey]
+	if !exists {
+		return "", "", "", fmt.Errorf("secret %s/%s has neither `%s` nor `%s`", namespace, r.Name, r.TokenKey, r.UsernameKey)
+	}
+
+	return "", string(username), string(secret.Data[r.PasswordKey]), nil
+}
+
+func NestedTimeout(obj map[string]interface{}, fields ...string) (time.Duration, error) {
+	timeout, exists, err := unstructured.NestedInt64(obj, fields...)
+	if err != nil {
+		return 0, err
+	}
+	if !exists || timeout <= 0 {
+		return DefaultInputTimeout, nil
+	}
+	return time.Duration(timeout) * time.Second, nil
+}
+
+func NestedPrometheusInputs(obj *unstructured.Unstructured) ([]PrometheusInput, error) {
+	items, _, err := unstructured.NestedSlice(obj.Object, "spec", "inputs", "prometheus")
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []PrometheusInput
+	names := make(map[string]bool)
+	for i, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("`spec.inputs.prometheus[%d]` must be an object", i)
+		}
+
+		input := PrometheusInput{}
+		for _, field := range []struct {
+			key   string
+			value *string
+		}{
+			{"name", &input.Name},
+			{"address", &input.Address},
+			{"query", &input.Query},
+		} {
+			value, _, err := unstructured.NestedString(entry, field.key)
+			if err != nil {
+				return nil, err
+			}
+			if value == "" {
+				return nil, fmt.Errorf("`spec.inputs.prometheus[%d].%s` not specified", i, field.key)
+			}
+			*field.value = value
+		}
+
+		if names[input.Name] {
+			return nil, fmt.Errorf("duplicate prometheus input `%s`", input.Name)
+		}
+		names[input.Name] = true
+
+		input.Timeout, err = NestedTimeout(entry, "timeout")
+		if err != nil {
+			return nil, err
+		}
+
+		input.SecretRef, err = NestedSecretRef(entry, "secretRef")
+		if err != nil {
+			return nil, fmt.Errorf("`spec.inputs.prometheus[%d]` %v", i, err)
+		}
+
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
+
+func (p PrometheusInput) Fetch(ctx context.Context, httpClient *http.Client, namespace string, now time.Time, secrets SecretResolver) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	client := prometheus.CreateClient(httpClient, p.Address)
+	if p.SecretRef != nil {
+		token, username, password, err := p.SecretRef.Credentials(ctx, namespace, secrets)
+		if err != nil {
+			return nil, err
+		}
+		client.Token = token
+		client.Username = username
+		client.Password = password
+	}
+
+	return client.Query(ctx, p.Query, now)
+}
+
+// FetchPrometheus runs every prometheus query of the policy, keyed by name.
+func (s *ScalingPolicy) FetchPrometheus(ctx context.Context, now time.Time, secrets SecretResolver) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, input := range s.Prometheus {
+		value, err := input.Fetch(ctx, s.HTTPClient, s.Namespace, now, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("prometheus input `%s`: %v", input.Name, err)
+		}
+		result[input.Name] = value
+	}
+	return result, nil
+}
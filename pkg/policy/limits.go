@@ -0,0 +1,48 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/open-policy-agent/opa/topdown"
+)
+
+type EvalTimeoutError struct {
+	Policy string
+	Reason string
+}
+
+func (e *EvalTimeoutError) Error() string {
+	return fmt.Sprintf("%s Scaling Policy evaluation timed out: %s", e.Policy, e.Reason)
+}
+
+// BudgetTracer counts evaluation steps and cancels the evaluation once the
+// limit is reached, so a runaway policy fails fast instead of burning CPU
+// until its timeout.
+type BudgetTracer struct {
+	Limit  int64
+	Count  int64
+	Cancel context.CancelFunc
+}
+
+func CreateBudgetTracer(limit int64, cancel context.CancelFunc) *BudgetTracer {
+	return &BudgetTracer{
+		Limit:  limit,
+		Cancel: cancel,
+	}
+}
+
+func (b *BudgetTracer) Enabled() bool {
+	return true
+}
+
+func (b *BudgetTracer) Trace(_ *topdown.Event) {
+	if atomic.AddInt64(&b.Count, 1) == b.Limit+1 {
+		b.Cancel()
+	}
+}
+
+func (b *BudgetTracer) Exceeded() bool {
+	return atomic.LoadInt64(&b.Count) > b.Limit
+}
@@ -0,0 +1,286 @@
+package policy
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/metrics/pkg/client/custom_metrics"
+	"k8s.io/metrics/pkg/client/external_metrics"
+)
+
+type CustomMetricInput struct {
+	Name           string
+	Metric         string
+	GroupKind      schema.GroupKind
+	ObjectName     string
+	Selector       labels.Selector
+	MetricSelector labels.Selector
+	Target         string
+}
+
+type ExternalMetricInput struct {
+	Name           string
+	Metric         string
+	MetricSelector labels.Selector
+}
+
+// NestedSelector reads a metav1.LabelSelector, returning nil when the field is
+// not set.
+func NestedSelector(obj map[string]interface{}, fields ...string) (labels.Selector, error) {
+	raw, exists, err := unstructured.NestedMap(obj, fields...)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	selector := &metav1.LabelSelector{}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(raw, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return metav1.LabelSelectorAsSelector(selector)
+}
+
+func NestedMetricEntries(obj *unstructured.Unstructured, kind string) ([]map[string]interface{}, error) {
+	items, _, err := unstructured.NestedSlice(obj.Object, "spec", "inputs", kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []map[string]interface{}
+	names := make(map[string]bool)
+	for i, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("`spec.inputs.%s[%d]` must be an object", kind, i)
+		}
+
+		for _, key := range []string{"name", "metric"} {
+			value, _, err := unstructured.NestedString(entry, key)
+			if err != nil {
+				return nil, err
+			}
+			if value == "" {
+				return nil, fmt.Errorf("`spec.inputs.%s[%d].%s` not specified", kind, i, key)
+			}
+		}
+
+		name := entry["name"].(string)
+		if names[name] {
+			return nil, fmt.Errorf("duplicate %s input `%s`", kind, name)
+		}
+		names[name] = true
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func NestedCustomMetricInputs(obj *unstructured.Unstructured) ([]CustomMetricInput, error) {
+	entries, err := NestedMetricEntries(obj, "customMetrics")
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []CustomMetricInput
+	for i, entry := range entries {
+		kind, _, _ := unstructured.NestedString(entry, "kind")
+		if kind == "" {
+			kind = "Pod"
+		}
+		group, _, _ := unstructured.NestedString(entry, "apiGroup")
+		objectName, _, _ := unstructured.NestedString(entry, "objectName")
+		target, _, _ := unstructured.NestedString(entry, "target")
+
+		selector, err := NestedSelector(entry, "selector")
+		if err != nil {
+			return nil, fmt.Errorf("`spec.inputs.customMetrics[%d].selector` %v", i, err)
+		}
+
+		metricSelector, err := NestedSelector(entry, "metricSelector")
+		if err != nil {
+			return nil, fmt.Errorf("`spec.inputs.customMetrics[%d].metricSelector` %v", i, err)
+		}
+
+		inputs = append(inputs, CustomMetricInput{
+			Name:           entry["name"].(string),
+			Metric:         entry["metric"].(string),
+			GroupKind:      schema.GroupKind{Group: group, Kind: kind},
+			ObjectName:     objectName,
+			Selector:       selector,
+			MetricSelector: metricSelector,
+			Target:         target,
+		})
+	}
+
+	return inputs, nil
+}
+
+// CheckCustomMetricTargets makes sure every custom metric names one of the
+// policy's targets. With named targets, pod metrics without an object name or
+// selector must say whose pods they describe.
+func CheckCustomMetricTargets(inputs []CustomMetricInput, targets []*PolicyTarget) error {
+	names := make(map[string]bool)
+	for _, target := range targets {
+		names[target.Name] = true
+	}
+
+	for _, input := range inputs {
+		if names[input.Target] {
+			continue
+		}
+		if input.Target == "" {
+			if input.ObjectName != "" || input.Selector != nil || input.GroupKind != (schema.GroupKind{Kind: "Pod"}) {
+				continue
+			}
+			return fmt.Errorf("custom metric input `%s` must name the target whose pods it describes", input.Name)
+		}
+		return fmt.Errorf("custom metric input `%s` names unknown target `%s`", input.Name, input.Target)
+	}
+
+	return nil
+}
+
+func NestedExternalMetricInputs(obj *unstructured.Unstructured) ([]ExternalMetricInput, error) {
+	entries, err := NestedMetricEntries(obj, "externalMetrics")
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []ExternalMetricInput
+	for i, entry := range entries {
+		metricSelector, err := NestedSelector(entry, "metricSelector")
+		if err != nil {
+			return nil, fmt.Errorf("`spec.inputs.externalMetrics[%d].metricSelector` %v", i, err)
+		}
+
+		inputs = append(inputs, ExternalMetricInput{
+			Name:           entry["name"].(string),
+			Metric:         entry["metric"].(string),
+			MetricSelector: metricSelector,
+		})
+	}
+
+	return inputs, nil
+}
+
+func MetricTimestamp(timestamp metav1.Time) interface{} {
+	if timestamp.IsZero() {
+		return nil
+	}
+	return timestamp.UnixNano()
+}
+
+func MetricValue(quantity resource.Quantity) float64 {
+	return float64(quantity.MilliValue()) / 1000
+}
+
+// FetchCustomMetrics reads every custom metric of the policy, keyed by name.
+// Pod metrics without an object name or selector describe the pods of their
+// target.
+func (s *ScalingPolicy) FetchCustomMetrics(client custom_metrics.CustomMetricsClient, targets map[string]*Target) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	if len(s.CustomMetrics) == 0 {
+		return result, nil
+	}
+	if client == nil {
+		return nil, fmt.Errorf("custom metrics client not configured")
+	}
+
+	for _, input := range s.CustomMetrics {
+		metricSelector := input.MetricSelector
+		if metricSelector == nil {
+			metricSelector = labels.Everything()
+		}
+
+		metrics := client.NamespacedMetrics(s.Namespace)
+
+		var values []interface{}
+		if input.ObjectName != "" {
+			value, err := metrics.GetForObject(input.GroupKind, input.ObjectName, input.Metric, metricSelector)
+			if err != nil {
+				return nil, fmt.Errorf("custom metric input `%s`: %v", input.Name, err)
+			}
+			values = append(values, CustomMetricValue(value.DescribedObject.Kind, value.DescribedObject.Name, value.Metric.Name, value.Timestamp, value.Value))
+		} else {
+			selector := input.Selector
+			if selector == nil && input.GroupKind == (schema.GroupKind{Kind: "Pod"}) && targets[input.Target] != nil {
+				selector = targets[input.Target].Selector
+			}
+			if selector == nil {
+				selector = labels.Everything()
+			}
+
+			list, err := metrics.GetForObjects(input.GroupKind, selector, input.Metric, metricSelector)
+			if err != nil {
+				return nil, fmt.Errorf("custom metric input `%s`: %v", input.Name, err)
+			}
+			for _, value := range list.Items {
+				values = append(values, CustomMetricValue(value.DescribedObject.Kind, value.DescribedObject.Name, value.Metric.Name, value.Timestamp, value.Value))
+			}
+		}
+
+		if values == nil {
+			values = []interface{}{}
+		}
+		result[input.Name] = values
+	}
+
+	return result, nil
+}
+
+func CustomMetricValue(kind, name, metric string, timestamp metav1.Time, value resource.Quantity) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":      kind,
+		"name":      name,
+		"metric":    metric,
+		"timestamp": MetricTimestamp(timestamp),
+		"value":     MetricValue(value),
+	}
+}
+
+// FetchExternalMetrics reads every external metric of the policy, keyed by name.
+func (s *ScalingPolicy) FetchExternalMetrics(client external_metrics.ExternalMetricsClient) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	if len(s.ExternalMetrics) == 0 {
+		return result, nil
+	}
+	if client == nil {
+		return nil, fmt.Errorf("external metrics client not configured")
+	}
+
+	for _, input := range s.ExternalMetrics {
+		metricSelector := input.MetricSelector
+		if metricSelector == nil {
+			metricSelector = labels.Everything()
+		}
+
+		list, err := client.NamespacedMetrics(s.Namespace).List(input.Metric, metricSelector)
+		if err != nil {
+			return nil, fmt.Errorf("external metric input `%s`: %v", input.Name, err)
+		}
+
+		values := []interface{}{}
+		for _, value := range list.Items {
+			metricLabels := value.MetricLabels
+			if metricLabels == nil {
+				metricLabels = map[string]string{}
+			}
+			values = append(values, map[string]interface{}{
+				"metric":    value.MetricName,
+				"labels":    metricLabels,
+				"timestamp": MetricTimestamp(value.Timestamp),
+				"value":     MetricValue(value.Value),
+			})
+		}
+		result[input.Name] = values
+	}
+
+	return result, nil
+}
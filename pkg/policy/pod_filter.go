@@ -0,0 +1,149 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+const (
+	MissingMetricsExclude = "exclude"
+	MissingMetricsInclude = "include"
+	MissingMetricsZero    = "zero"
+)
+
+const (
+	ExcludedPhase          = "phase"
+	ExcludedTerminating    = "terminating"
+	ExcludedUnready        = "unready"
+	ExcludedStarting       = "starting"
+	ExcludedMissingMetrics = "missingMetrics"
+	ExcludedStaleMetrics   = "staleMetrics"
+)
+
+// PodFilter decides which pods count toward the policy input. The zero value
+// keeps every pod that has metrics.
+type PodFilter struct {
+	Phases             []string
+	RequireReady       bool
+	ExcludeTerminating bool
+	StartupGracePeriod time.Duration
+	MissingMetrics     string
+}
+
+type ExcludedPod struct {
+	Pod    *coreV1.Pod `json:"pod"`
+	Reason string      `json:"reason"`
+}
+
+func NestedPodFilter(obj *unstructured.Unstructured) (PodFilter, error) {
+	filter := PodFilter{MissingMetrics: MissingMetricsExclude}
+
+	phases, _, err := unstructured.NestedStringSlice(obj.Object, "spec", "podFilter", "phases")
+	if err != nil {
+		return filter, err
+	}
+	filter.Phases = phases
+
+	filter.RequireReady, _, err = unstructured.NestedBool(obj.Object, "spec", "podFilter", "requireReady")
+	if err != nil {
+		return filter, err
+	}
+
+	filter.ExcludeTerminating, _, err = unstructured.NestedBool(obj.Object, "spec", "podFilter", "excludeTerminating")
+	if err != nil {
+		return filter, err
+	}
+
+	grace, _, err := unstructured.NestedInt64(obj.Object, "spec", "podFilter", "startupGracePeriod")
+	if err != nil {
+		return filter, err
+	}
+	filter.StartupGracePeriod = time.Duration(grace) * time.Second
+
+	missingMetrics, _, err := unstructured.NestedString(obj.Object, "spec", "podFilter", "missingMetrics")
+	if err != nil {
+		return filter, err
+	}
+	switch missingMetrics {
+	case "":
+	case MissingMetricsExclude, MissingMetricsInclude, MissingMetricsZero:
+		filter.MissingMetrics = missingMetrics
+	default:
+		return filter, fmt.Errorf("`spec.podFilter.missingMetrics` must be one of `%s`, `%s` or `%s`", MissingMetricsExclude, MissingMetricsInclude, MissingMetricsZero)
+	}
+
+	return filter, nil
+}
+
+// Exclude returns why a pod should be left out of the input, or an empty
+// string if it counts.
+func (f PodFilter) Exclude(pod *coreV1.Pod, now time.Time) string {
+	if len(f.Phases) > 0 {
+		allowed := false
+		for _, phase := range f.Phases {
+			if string(pod.Status.Phase) == phase {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return ExcludedPhase
+		}
+	}
+
+	if f.ExcludeTerminating && pod.DeletionTimestamp != nil {
+		return ExcludedTerminating
+	}
+
+	if f.RequireReady && !IsPodReady(pod) {
+		return ExcludedUnready
+	}
+
+	if f.StartupGracePeriod > 0 {
+		if pod.Status.StartTime == nil || now.Sub(pod.Status.StartTime.Time) < f.StartupGracePeriod {
+			return ExcludedStarting
+		}
+	}
+
+	return ""
+}
+
+func IsPodReady(pod *coreV1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == coreV1.PodReady {
+			return condition.Status == coreV1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// ZeroPodMetrics stands in for a pod without metrics, reporting no usage for
+// each of its containers.
+func ZeroPodMetrics(pod *coreV1.Pod, now time.Time) *metricsv1beta1.PodMetrics {
+	podMetrics := &metricsv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Labels:    pod.Labels,
+		},
+		Timestamp: metav1.NewTime(now),
+	}
+
+	for _, container := range pod.Spec.Containers {
+		podMetrics.Containers = append(podMetrics.Containers, metricsv1beta1.ContainerMetrics{
+			Name: container.Name,
+			Usage: coreV1.ResourceList{
+				coreV1.ResourceCPU:    resource.MustParse("0"),
+				coreV1.ResourceMemory: resource.MustParse("0"),
+			},
+		})
+	}
+
+	return podMetrics
+}
@@ -0,0 +1,245 @@
+package policy
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	appsV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/theMagicalKarp/agronomist/pkg/storage"
+)
+
+func ownerRef(kind, name string, uid types.UID) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, UID: uid, Controller: &controller}}
+}
+
+func createReplicaSet(name string, uid types.UID, app string, owners []metav1.OwnerReference) *appsV1.ReplicaSet {
+	return &appsV1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "apps",
+			Name:            name,
+			UID:             uid,
+			Labels:          map[string]string{"app": app},
+			OwnerReferences: owners,
+		},
+	}
+}
+
+func createPod(name, app string, owners []metav1.OwnerReference) *coreV1.Pod {
+	return &coreV1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "apps",
+			Name:            name,
+			UID:             types.UID("pod-" + name),
+			Labels:          map[string]string{"app": app},
+			OwnerReferences: owners,
+		},
+	}
+}
+
+func createPodStore(t *testing.T, ctx context.Context, objects ...runtime.Object) (*storage.Store, *fake.Clientset) {
+	clientset := fake.NewSimpleClientset(objects...)
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+
+	store := &storage.Store{
+		PodCache:        storage.CreatePodCache(factory.Core().V1().Pods().Informer()),
+		ReplicaSetCache: storage.CreateReplicaSetCache(factory.Apps().V1().ReplicaSets().Informer()),
+	}
+	go store.PodCache.Start(ctx)
+	go store.ReplicaSetCache.Start(ctx)
+
+	if !cache.WaitForCacheSync(ctx.Done(), store.PodCache.Informer.HasSynced, store.ReplicaSetCache.Informer.HasSynced) {
+		t.Fatalf("timed out waiting for caches to sync")
+	}
+
+	return store, clientset
+}
+
+func resolvePods(t *testing.T, sp *ScalingPolicy, target *Target, store *storage.Store) string {
+	t.Helper()
+
+	pods, err := sp.ResolvePods(target, store)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Strings(pods)
+	return strings.Join(pods, ",")
+}
+
+func TestResolvePods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deploymentUID := types.UID("deployment-web")
+	owned := ownerRef("Deployment", "web", deploymentUID)
+
+	store, _ := createPodStore(t, ctx,
+		createReplicaSet("web-1", "rs-web-1", "web", owned),
+		createReplicaSet("web-2", "rs-web-2", "web", owned),
+		createReplicaSet("web-old", "rs-web-old", "web", nil),
+		createReplicaSet("api-1", "rs-api-1", "api", ownerRef("Deployment", "api", "deployment-api")),
+		createPod("web-1-a", "web", ownerRef("ReplicaSet", "web-1", "rs-web-1")),
+		createPod("web-1-b", "web", ownerRef("ReplicaSet", "web-1", "rs-web-1")),
+		createPod("web-2-a", "web", ownerRef("ReplicaSet", "web-2", "rs-web-2")),
+		createPod("web-old-a", "web", ownerRef("ReplicaSet", "web-old", "rs-web-old")),
+		createPod("stray", "web", nil),
+		createPod("api-1-a", "api", ownerRef("ReplicaSet", "api-1", "rs-api-1")),
+		createPod("db-0", "db", ownerRef("StatefulSet", "db", "statefulset-db")),
+	)
+
+	webSelector := labels.SelectorFromSet(labels.Set{"app": "web"})
+
+	tests := []struct {
+		name       string
+		resolution string
+		target     *Target
+		expected   string
+		err        string
+	}{
+		{
+			name:       "owner follows replicasets",
+			resolution: PodResolutionOwner,
+			target:     &Target{Ref: TargetRef{Kind: KindDeployment, Name: "web"}, UID: deploymentUID, Selector: webSelector},
+			expected:   "web-1-a,web-1-b,web-2-a",
+		},
+		{
+			name:       "selector includes orphaned and bare pods",
+			resolution: PodResolutionSelector,
+			target:     &Target{Ref: TargetRef{Kind: KindDeployment, Name: "web"}, UID: deploymentUID, Selector: webSelector},
+			expected:   "stray,web-1-a,web-1-b,web-2-a,web-old-a",
+		},
+		{
+			name:       "owner of a statefulset",
+			resolution: PodResolutionOwner,
+			target:     &Target{Ref: TargetRef{Kind: KindStatefulSet, Name: "db"}, UID: "statefulset-db"},
+			expected:   "db-0",
+		},
+		{
+			name:       "selector with set based requirements",
+			resolution: PodResolutionSelector,
+			target: &Target{
+				Ref:      TargetRef{Kind: KindDeployment, Name: "web"},
+				Selector: mustParseSelector(t, "app in (web, api)"),
+			},
+			expected: "api-1-a,stray,web-1-a,web-1-b,web-2-a,web-old-a",
+		},
+		{
+			name:     "unset follows owners of a deployment",
+			target:   &Target{Ref: TargetRef{APIVersion: "apps/v1", Kind: KindDeployment, Name: "web"}, UID: deploymentUID, Selector: webSelector},
+			expected: "web-1-a,web-1-b,web-2-a",
+		},
+		{
+			name:     "unset uses the selector of other kinds",
+			target:   &Target{Ref: TargetRef{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "web"}, UID: deploymentUID, Selector: webSelector},
+			expected: "stray,web-1-a,web-1-b,web-2-a,web-old-a",
+		},
+		{
+			name:       "owner of other kinds when asked",
+			resolution: PodResolutionOwner,
+			target:     &Target{Ref: TargetRef{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "web"}, UID: deploymentUID, Selector: webSelector},
+			expected:   "web-1-a,web-1-b,web-2-a",
+		},
+		{
+			name:   "unset without a selector",
+			target: &Target{Ref: TargetRef{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "web"}, UID: deploymentUID},
+			err:    "Rollout/web has no selector",
+		},
+		{
+			name:       "selector without a selector",
+			resolution: PodResolutionSelector,
+			target:     &Target{Ref: TargetRef{Kind: KindDeployment, Name: "web"}, UID: deploymentUID},
+			err:        "Deployment/web has no selector",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sp := &ScalingPolicy{Namespace: "apps", Name: "web", PodResolution: test.resolution}
+
+			if test.err != "" {
+				_, err := sp.ResolvePods(test.target, store)
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if pods := resolvePods(t, sp, test.target, store); pods != test.expected {
+				t.Fatalf("expected pods %s, got %s", test.expected, pods)
+			}
+		})
+	}
+}
+
+// TestResolvePodsAdoption checks owner resolution picks up a ReplicaSet once
+// the deployment adopts it, and drops one it releases.
+func TestResolvePodsAdoption(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deploymentUID := types.UID("deployment-web")
+	owned := ownerRef("Deployment", "web", deploymentUID)
+
+	store, clientset := createPodStore(t, ctx,
+		createReplicaSet("web-1", "rs-web-1", "web", owned),
+		createReplicaSet("web-old", "rs-web-old", "web", nil),
+		createPod("web-1-a", "web", ownerRef("ReplicaSet", "web-1", "rs-web-1")),
+		createPod("web-old-a", "web", ownerRef("ReplicaSet", "web-old", "rs-web-old")),
+	)
+
+	sp := &ScalingPolicy{Namespace: "apps", Name: "web", PodResolution: PodResolutionOwner}
+	target := &Target{Ref: TargetRef{Kind: KindDeployment, Name: "web"}, UID: deploymentUID}
+
+	if pods := resolvePods(t, sp, target, store); pods != "web-1-a" {
+		t.Fatalf("expected the orphaned replicaset to be ignored, got %s", pods)
+	}
+
+	steps := []struct {
+		name       string
+		replicaSet *appsV1.ReplicaSet
+		expected   string
+	}{
+		{"adopted", createReplicaSet("web-old", "rs-web-old", "web", owned), "web-1-a,web-old-a"},
+		{"released", createReplicaSet("web-1", "rs-web-1", "web", nil), "web-old-a"},
+	}
+
+	for _, step := range steps {
+		_, err := clientset.AppsV1().ReplicaSets("apps").Update(ctx, step.replicaSet, metav1.UpdateOptions{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+
+		pods := ""
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			pods = resolvePods(t, sp, target, store)
+			if pods == step.expected {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if pods != step.expected {
+			t.Fatalf("%s: expected pods %s, got %s", step.name, step.expected, pods)
+		}
+	}
+}
+
+func mustParseSelector(t *testing.T, selector string) labels.Selector {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		t.Fatalf("invalid selector: %v", err)
+	}
+	return parsed
+}
@@ -2,12 +2,14 @@ package policy
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/open-policy-agent/opa/topdown"
 
 	coreV1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -18,38 +20,74 @@ import (
 	"github.com/theMagicalKarp/agronomist/pkg/storage"
 )
 
+const DefaultQuery = "data.main.scale"
+
+const (
+	PodResolutionSelector = "selector"
+	PodResolutionOwner    = "owner"
+)
+
 type ScalingPolicy struct {
 	Name            string
-	Deployment      string
+	Targets         []*PolicyTarget
 	Namespace       string
 	ResourceVersion string
+	StatusNamespace string
+	Query           string
+	Sources         []SourceRef
+	SourceVersions  map[string]string
+	Modules         map[string]string
+	Data            map[string]interface{}
 	Compiler        *ast.Compiler
-
-	Min           int
-	Max           int
-	MaxStepUp     int
-	MaxStepDown   int
-	UpThrottle    time.Duration
-	DownThrottle  time.Duration
-	CheckInterval int
-	LastScale     time.Time
+	PreparedQuery   rego.PreparedEvalQuery
+
+	Min               int
+	Max               int
+	MaxStepUp         int
+	MaxStepDown       int
+	UpThrottle        time.Duration
+	DownThrottle      time.Duration
+	CheckInterval     int
+	EvalTimeout       time.Duration
+	EvalBudget        int64
+	Debug             bool
+	LastScale         time.Time
+	LastDecision      map[string]interface{}
+	LastTrace         *EvalTrace
+	LastRecordedTrace *EvalTrace
+	LastError         *string
+	State             map[string]interface{}
+	LastRecordedState map[string]interface{}
+	HistoryWindow     int
+	Evaluations       int64
+	PodResolution     string
+	PodFilter         PodFilter
+
+	Prometheus      []PrometheusInput
+	CustomMetrics   []CustomMetricInput
+	ExternalMetrics []ExternalMetricInput
+	Resources       []ResourceInput
+	Watched         []storage.ResourceKey
+	HTTP            []HTTPInput
+	HTTPClient      *http.Client
+	HTTPCache       *HTTPCache
 }
 
-func CreateScalingPolicy(obj *unstructured.Unstructured) (*ScalingPolicy, error) {
-	deployment, exists, err := unstructured.NestedString(obj.Object, "spec", "deployment")
+func CreateScalingPolicy(obj *unstructured.Unstructured, resolver SourceResolver) (*ScalingPolicy, error) {
+	sources, err := LoadSources(context.Background(), obj, resolver)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s Scaling Policy %v", obj.GetName(), err)
 	}
-	if !exists {
-		return nil, fmt.Errorf("%s Scaling Policy `spec.deployment` not specified!")
+	if len(sources.Modules) == 0 {
+		return nil, fmt.Errorf("%s Scaling Policy `spec.rego` not specified!", obj.GetName())
 	}
 
-	regoSrc, exists, err := unstructured.NestedString(obj.Object, "spec", "rego")
+	query, exists, err := unstructured.NestedString(obj.Object, "spec", "query")
 	if err != nil {
 		return nil, err
 	}
-	if !exists {
-		return nil, fmt.Errorf("%s Scaling Policy `spec.rego` not specified!")
+	if !exists || query == "" {
+		query = DefaultQuery
 	}
 
 	min, exists, err := unstructured.NestedInt64(obj.Object, "spec", "min")
@@ -57,7 +95,7 @@ func CreateScalingPolicy(obj *unstructured.Unstructured) (*ScalingPolicy, error)
 		return nil, err
 	}
 	if !exists {
-		return nil, fmt.Errorf("%s Scaling Policy `spec.min` not specified!")
+		return nil, fmt.Errorf("%s Scaling Policy `spec.min` not specified!", obj.GetName())
 	}
 
 	max, exists, err := unstructured.NestedInt64(obj.Object, "spec", "max")
@@ -65,7 +103,7 @@ func CreateScalingPolicy(obj *unstructured.Unstructured) (*ScalingPolicy, error)
 		return nil, err
 	}
 	if !exists {
-		return nil, fmt.Errorf("%s Scaling Policy `spec.max` not specified!")
+		return nil, fmt.Errorf("%s Scaling Policy `spec.max` not specified!", obj.GetName())
 	}
 
 	maxStepUp, exists, err := unstructured.NestedInt64(obj.Object, "spec", "maxStepUp")
@@ -73,7 +111,7 @@ func CreateScalingPolicy(obj *unstructured.Unstructured) (*ScalingPolicy, error)
 		return nil, err
 	}
 	if !exists {
-		return nil, fmt.Errorf("%s Scaling Policy `spec.maxStepUp` not specified!")
+		return nil, fmt.Errorf("%s Scaling Policy `spec.maxStepUp` not specified!", obj.GetName())
 	}
 
 	maxStepDown, exists, err := unstructured.NestedInt64(obj.Object, "spec", "maxStepDown")
@@ -81,7 +119,7 @@ func CreateScalingPolicy(obj *unstructured.Unstructured) (*ScalingPolicy, error)
 		return nil, err
 	}
 	if !exists {
-		return nil, fmt.Errorf("%s Scaling Policy `spec.maxStepDown` not specified!")
+		return nil, fmt.Errorf("%s Scaling Policy `spec.maxStepDown` not specified!", obj.GetName())
 	}
 
 	upDelay, exists, err := unstructured.NestedInt64(obj.Object, "spec", "upDelay")
@@ -89,7 +127,7 @@ func CreateScalingPolicy(obj *unstructured.Unstructured) (*ScalingPolicy, error)
 		return nil, err
 	}
 	if !exists {
-		return nil, fmt.Errorf("%s Scaling Policy `spec.upDelay` not specified!")
+		return nil, fmt.Errorf("%s Scaling Policy `spec.upDelay` not specified!", obj.GetName())
 	}
 
 	downDelay, exists, err := unstructured.NestedInt64(obj.Object, "spec", "downDelay")
@@ -97,7 +135,7 @@ func CreateScalingPolicy(obj *unstructured.Unstructured) (*ScalingPolicy, error)
 		return nil, err
 	}
 	if !exists {
-		return nil, fmt.Errorf("%s Scaling Policy `spec.downDelay` not specified!")
+		return nil, fmt.Errorf("%s Scaling Policy `spec.downDelay` not specified!", obj.GetName())
 	}
 
 	interval, exists, err := unstructured.NestedInt64(obj.Object, "spec", "interval")
@@ -105,23 +143,127 @@ func CreateScalingPolicy(obj *unstructured.Unstructured) (*ScalingPolicy, error)
 		return nil, err
 	}
 	if !exists {
-		return nil, fmt.Errorf("%s Scaling Policy `spec.interval` not specified!")
+		return nil, fmt.Errorf("%s Scaling Policy `spec.interval` not specified!", obj.GetName())
+	}
+
+	evalTimeout, _, err := unstructured.NestedInt64(obj.Object, "spec", "evalTimeout")
+	if err != nil {
+		return nil, err
+	}
+	if evalTimeout < 0 {
+		return nil, fmt.Errorf("%s Scaling Policy `spec.evalTimeout` must not be negative!", obj.GetName())
 	}
 
-	compiler, err := ast.CompileModules(map[string]string{
-		"main.rego": regoSrc,
-	})
+	evalBudget, _, err := unstructured.NestedInt64(obj.Object, "spec", "evalBudget")
+	if err != nil {
+		return nil, err
+	}
+	if evalBudget < 0 {
+		return nil, fmt.Errorf("%s Scaling Policy `spec.evalBudget` must not be negative!", obj.GetName())
+	}
 
+	debug, _, err := unstructured.NestedBool(obj.Object, "spec", "debug")
 	if err != nil {
 		return nil, err
 	}
 
+	historyWindow, _, err := unstructured.NestedInt64(obj.Object, "spec", "historyWindow")
+	if err != nil {
+		return nil, err
+	}
+	if historyWindow < 0 {
+		return nil, fmt.Errorf("%s Scaling Policy `spec.historyWindow` must not be negative!", obj.GetName())
+	}
+
+	targets, err := NestedTargets(obj, PolicyTarget{
+		Min:         int(min),
+		Max:         int(max),
+		MaxStepUp:   int(maxStepUp),
+		MaxStepDown: int(maxStepDown),
+	}, int(historyWindow))
+	if err != nil {
+		return nil, fmt.Errorf("%s Scaling Policy %v", obj.GetName(), err)
+	}
+
+	prometheus, err := NestedPrometheusInputs(obj)
+	if err != nil {
+		return nil, fmt.Errorf("%s Scaling Policy %v", obj.GetName(), err)
+	}
+
+	customMetrics, err := NestedCustomMetricInputs(obj)
+	if err != nil {
+		return nil, fmt.Errorf("%s Scaling Policy %v", obj.GetName(), err)
+	}
+
+	err = CheckCustomMetricTargets(customMetrics, targets)
+	if err != nil {
+		return nil, fmt.Errorf("%s Scaling Policy %v", obj.GetName(), err)
+	}
+
+	externalMetrics, err := NestedExternalMetricInputs(obj)
+	if err != nil {
+		return nil, fmt.Errorf("%s Scaling Policy %v", obj.GetName(), err)
+	}
+
+	resources, err := NestedResourceInputs(obj)
+	if err != nil {
+		return nil, fmt.Errorf("%s Scaling Policy %v", obj.GetName(), err)
+	}
+
+	httpInputs, err := NestedHTTPInputs(obj)
+	if err != nil {
+		return nil, fmt.Errorf("%s Scaling Policy %v", obj.GetName(), err)
+	}
+
+	podResolution, _, err := unstructured.NestedString(obj.Object, "spec", "podResolution")
+	if err != nil {
+		return nil, err
+	}
+	if podResolution != "" && podResolution != PodResolutionSelector && podResolution != PodResolutionOwner {
+		return nil, fmt.Errorf("%s Scaling Policy `spec.podResolution` must be `%s` or `%s`!", obj.GetName(), PodResolutionSelector, PodResolutionOwner)
+	}
+
+	podFilter, err := NestedPodFilter(obj)
+	if err != nil {
+		return nil, fmt.Errorf("%s Scaling Policy %v", obj.GetName(), err)
+	}
+
+	compiler, err := ast.CompileModules(sources.Modules)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = CheckQuery(compiler, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s Scaling Policy %v", obj.GetName(), err)
+	}
+
+	preparedQuery, err := rego.New(
+		rego.Query(query),
+		rego.Compiler(compiler),
+		rego.Store(inmem.NewFromObject(sources.Data)),
+	).PrepareForEval(context.Background())
+
+	if err != nil {
+		return nil, err
+	}
+
+	// prometheus and http inputs share one client
+	httpClient := http.DefaultClient
+
 	return &ScalingPolicy{
 		Name:            obj.GetName(),
-		Deployment:      deployment,
+		Targets:         targets,
 		Namespace:       obj.GetNamespace(),
 		ResourceVersion: obj.GetResourceVersion(),
+		Query:           query,
+		Sources:         sources.Refs,
+		SourceVersions:  sources.Versions,
+		Modules:         sources.Modules,
+		Data:            sources.Data,
 		Compiler:        compiler,
+		PreparedQuery:   preparedQuery,
 
 		Min:           int(min),
 		Max:           int(max),
@@ -130,20 +272,79 @@ func CreateScalingPolicy(obj *unstructured.Unstructured) (*ScalingPolicy, error)
 		UpThrottle:    time.Duration(upDelay) * time.Second,
 		DownThrottle:  time.Duration(downDelay) * time.Second,
 		CheckInterval: int(interval),
+		EvalTimeout:   time.Duration(evalTimeout) * time.Second,
+		EvalBudget:    evalBudget,
+		Debug:         debug,
+		HistoryWindow: int(historyWindow),
+		PodResolution: podResolution,
+		PodFilter:     podFilter,
+
+		Prometheus:      prometheus,
+		CustomMetrics:   customMetrics,
+		ExternalMetrics: externalMetrics,
+		Resources:       resources,
+		HTTP:            httpInputs,
+		HTTPClient:      httpClient,
+		HTTPCache:       CreateHTTPCache(httpClient),
 	}, nil
 }
 
+func CheckQuery(compiler *ast.Compiler, query string) error {
+	ref, err := ast.ParseRef(query)
+	if err != nil {
+		return fmt.Errorf("invalid query `%s`: %v", query, err)
+	}
+
+	if !ref.HasPrefix(ast.DefaultRootRef) {
+		return fmt.Errorf("query `%s` must reference `data`", query)
+	}
+
+	if len(compiler.GetRulesForVirtualDocument(ref)) == 0 {
+		return fmt.Errorf("query `%s` does not match any rule", query)
+	}
+
+	return nil
+}
+
 func (s *ScalingPolicy) Run(ctx context.Context, store *storage.Store) {
 
 	for {
 		select {
 		case <-time.After(time.Duration(s.CheckInterval) * time.Second):
-			scale, err := s.DetermineScale(ctx, store)
+			decision, targets, err := s.DetermineScale(ctx, store)
+
+			if s.Debug && s.LastTrace != nil {
+				traceErr := s.RecordTrace(ctx, s.LastTrace, store)
+				if traceErr != nil {
+					fmt.Println(traceErr)
+				}
+			}
+
+			statusErr := s.RecordError(ctx, err, store)
+			if statusErr != nil {
+				fmt.Println(statusErr)
+			}
+
 			if err != nil {
 				fmt.Println(err)
 				continue
 			}
-			err = s.Scale(ctx, scale, store)
+
+			err = s.RecordDecision(ctx, decision, store)
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			// a decision without state clears whatever was stored before
+			if decision.State != nil || s.State != nil {
+				s.State = decision.State
+				err = s.RecordState(ctx, store)
+				if err != nil {
+					fmt.Println(err)
+				}
+			}
+
+			err = s.Scale(ctx, decision, targets, store)
 
 			if err != nil {
 				fmt.Println(err)
@@ -156,175 +357,329 @@ func (s *ScalingPolicy) Run(ctx context.Context, store *storage.Store) {
 	}
 }
 
-func (s *ScalingPolicy) Normalize(scale, replicas int) int {
-	if scale < s.Min {
-		scale = s.Min
+// Scale plans the decision for every target the policy was evaluated with
+// and applies it, recording an event on each target that was scaled.
+func (s *ScalingPolicy) Scale(ctx context.Context, decision *Decision, targets map[string]*Target, store *storage.Store) error {
+	replicas := make(map[string]int)
+	for name, target := range targets {
+		replicas[name] = target.Replicas
 	}
 
-	if scale > s.Max {
-		scale = s.Max
+	now := time.Now()
+	scales, hold := s.Plan(decision, replicas, now)
+	if hold != "" {
+		fmt.Println(hold)
+		return nil
 	}
 
-	// going up
-	if scale > replicas {
-		if scale-replicas > s.MaxStepUp {
-			return replicas + s.MaxStepUp
-		}
-		return scale
+	err := s.ApplyScales(ctx, targets, scales, decision, store)
+	if err != nil {
+		return err
 	}
 
-	// going down
-	if scale < replicas {
-		if replicas-scale > s.MaxStepDown {
-			return replicas - s.MaxStepUp
+	// a failed scale is rolled back, so it shouldn't throttle the next one
+	s.LastScale = now
+
+	for _, pt := range s.Targets {
+		if scales[pt.Name] == replicas[pt.Name] {
+			continue
+		}
+
+		eventErr := s.RecordScaleEvent(ctx, targets[pt.Name], replicas[pt.Name], scales[pt.Name], decision, store)
+		if eventErr != nil {
+			err = eventErr
 		}
-		return scale
 	}
 
-	return scale
+	return err
 }
 
-func (s *ScalingPolicy) Scale(ctx context.Context, scale int, store *storage.Store) error {
-	deployment, exists, err := store.DeploymentCache.GetDeployment(s.Namespace, s.Deployment)
+func (s *ScalingPolicy) RecordScaleEvent(ctx context.Context, target *Target, from, to int, decision *Decision, store *storage.Store) error {
+	message := fmt.Sprintf("ScalingPolicy %s scaled from %d to %d", s.Name, from, to)
+	if decision.Reason != "" {
+		message = fmt.Sprintf("%s: %s", message, decision.Reason)
+	}
+
+	now := metav1.Now()
+	_, err := store.ClientSet.CoreV1().Events(s.Namespace).Create(ctx, &coreV1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s.", target.Ref.Name),
+			Namespace:    s.Namespace,
+			Labels:       decision.Labels,
+		},
+		InvolvedObject: coreV1.ObjectReference{
+			APIVersion:      target.Ref.APIVersion,
+			Kind:            target.Ref.Kind,
+			Name:            target.Ref.Name,
+			Namespace:       target.Namespace,
+			UID:             target.UID,
+			ResourceVersion: target.ResourceVersion,
+		},
+		Reason:         "Scaled",
+		Message:        message,
+		Type:           coreV1.EventTypeNormal,
+		Source:         coreV1.EventSource{Component: "agronomist"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}, metav1.CreateOptions{})
 
-	if !exists {
-		fmt.Printf("Deployment DNE %s/%s\n", s.Namespace, s.Deployment)
-		return nil
-	}
+	return err
+}
 
+// DetermineScale evaluates the policy, returning the targets its input was
+// built from so they aren't looked up again to scale them.
+func (s *ScalingPolicy) DetermineScale(ctx context.Context, storage *storage.Store) (*Decision, map[string]*Target, error) {
+	targets, err := s.GetTargets(ctx, storage)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	replicas := 1
-	if deployment.Spec.Replicas != nil {
-		replicas = int(*deployment.Spec.Replicas)
+	input, err := s.BuildInput(ctx, targets, storage)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	scale = s.Normalize(scale, replicas)
+	_, decision, err := s.Evaluate(ctx, input)
+	return decision, targets, err
+}
 
-	if replicas == scale {
-		fmt.Println("nothing to do")
-		return nil
+func (s *ScalingPolicy) BuildInput(ctx context.Context, targets map[string]*Target, storage *storage.Store) (map[string]interface{}, error) {
+	// without metrics every pod is treated as missing them, so the pod filter
+	// still decides what the policy sees
+	namespaceMetrics, err := storage.PodMetricsCache.GetPodMetrics(ctx, s.Namespace)
+	if err != nil {
+		fmt.Printf("%s Scaling Policy failed to fetch pod metrics: %v\n", s.Name, err)
+		namespaceMetrics = nil
 	}
 
-	if scale > replicas && time.Since(s.LastScale) < s.UpThrottle {
-		fmt.Println("TOO SOON UP!")
-		return nil
+	now := time.Now()
+
+	targetInputs := make(map[string]*TargetInput)
+	for _, pt := range s.Targets {
+		targetInput, err := s.CollectPods(targets[pt.Name], namespaceMetrics, now, storage)
+		if err != nil {
+			return nil, err
+		}
+		targetInputs[pt.Name] = targetInput
 	}
 
-	if scale < replicas && time.Since(s.LastScale) < s.DownThrottle {
-		fmt.Println("TOO SOON DOWN!")
-		return nil
+	prometheus, err := s.FetchPrometheus(ctx, now, storage)
+	if err != nil {
+		return nil, err
 	}
 
-	s.LastScale = time.Now()
-	fmt.Printf("scaling to %d\n", scale)
+	customMetrics, err := s.FetchCustomMetrics(storage.CustomMetricsClient, targets)
+	if err != nil {
+		return nil, err
+	}
 
-	deploymentsClient := store.ClientSet.AppsV1().Deployments(s.Namespace)
+	externalMetrics, err := s.FetchExternalMetrics(storage.ExternalMetricsClient)
+	if err != nil {
+		return nil, err
+	}
 
-	foo, err := deploymentsClient.GetScale(ctx, s.Deployment, metav1.GetOptions{})
+	resources, err := s.FetchResources(ctx, storage)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	foo.Spec.Replicas = int32(scale)
+	httpInputs := s.FetchHTTP(ctx, now, storage)
 
-	_, err = deploymentsClient.UpdateScale(ctx, s.Deployment, foo, metav1.UpdateOptions{})
-	return err
+	input := s.InputTargets(now, targetInputs)
+	input["state"] = s.InputState()
+	input["prometheus"] = prometheus
+	input["customMetrics"] = customMetrics
+	input["externalMetrics"] = externalMetrics
+	input["resources"] = resources
+	input["http"] = httpInputs
 
+	return input, nil
 }
 
-func (s *ScalingPolicy) DetermineScale(ctx context.Context, storage *storage.Store) (int, error) {
-	deployment, exists, err := storage.DeploymentCache.GetDeployment(s.Namespace, s.Deployment)
-
+// CollectPods resolves the target's pods and their metrics, applying the
+// policy's pod filter.
+func (s *ScalingPolicy) CollectPods(target *Target, namespaceMetrics map[string]*metricsv1beta1.PodMetrics, now time.Time, storage *storage.Store) (*TargetInput, error) {
+	podNames, err := s.ResolvePods(target, storage)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	if !exists {
-		return 0, fmt.Errorf("Deployment DNE")
+	input := &TargetInput{
+		Target:       target,
+		ExcludedPods: []ExcludedPod{},
 	}
 
-	var podNames []string
-	for _, replicaSet := range storage.ReplicaSetCache.GetReplicaSetsByOwnerUID(deployment.UID) {
-		rs, exists, err := storage.ReplicaSetCache.GetReplicaSet(s.Namespace, replicaSet)
+	for _, podName := range podNames {
+		pod, exists, err := storage.PodCache.GetPod(s.Namespace, podName)
 
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 
 		if !exists {
-			fmt.Println("? Replicaset DNE ?")
+			fmt.Println("? POD DNE ?")
 			continue
 		}
 
-		podNames = append(podNames, storage.PodCache.GetPodsByOwnerUID(rs.UID)...)
-	}
-
-	var podMetrics []*metricsv1beta1.PodMetrics
-	var pods []*coreV1.Pod
-	for _, podName := range podNames {
-		podMetric, err := storage.MetricsClientset.MetricsV1beta1().PodMetricses(s.Namespace).Get(ctx, podName, metav1.GetOptions{})
-		if err != nil {
-			// shoulds pods be included if metrics DNE?
-			fmt.Printf("Pod Metrics not ready %s\n", podName)
+		if reason := s.PodFilter.Exclude(pod, now); reason != "" {
+			input.ExcludedPods = append(input.ExcludedPods, ExcludedPod{Pod: pod, Reason: reason})
 			continue
 		}
-		podMetrics = append(podMetrics, podMetric)
-
-		pod, exists, err := storage.PodCache.GetPod(s.Namespace, podName)
 
-		if err != nil {
-			return 0, err
+		podMetric := namespaceMetrics[podName]
+		reason := ""
+		if podMetric == nil {
+			reason = ExcludedMissingMetrics
+		} else if storage.PodMetricsCache.IsStale(podMetric, now) {
+			reason = ExcludedStaleMetrics
 		}
 
-		if !exists {
-			fmt.Println("? POD DNE ?")
+		if reason != "" {
+			switch s.PodFilter.MissingMetrics {
+			case MissingMetricsInclude:
+				input.Pods = append(input.Pods, pod)
+			case MissingMetricsZero:
+				input.Pods = append(input.Pods, pod)
+				input.PodMetrics = append(input.PodMetrics, ZeroPodMetrics(pod, now))
+			default:
+				fmt.Printf("Pod Metrics not ready %s (%s)\n", podName, reason)
+				input.ExcludedPods = append(input.ExcludedPods, ExcludedPod{Pod: pod, Reason: reason})
+			}
 			continue
 		}
 
-		pods = append(pods, pod)
+		input.PodMetrics = append(input.PodMetrics, podMetric)
+		input.Pods = append(input.Pods, pod)
 	}
 
-	input := map[string]interface{}{
-		"podMetrics": podMetrics,
-		"deployment": deployment,
-		"pods":       pods,
+	return input, nil
+}
+
+// InputContext describes the policy itself so rego can reason about its own
+// limits. Times are in nanoseconds like time.now_ns. Replica counts are only
+// included for a single target, named targets carry their own.
+func (s *ScalingPolicy) InputContext(now time.Time, target *Target) map[string]interface{} {
+	s.Evaluations++
+
+	var lastScaleTime interface{}
+	if !s.LastScale.IsZero() {
+		lastScaleTime = s.LastScale.UnixNano()
 	}
 
-	r := rego.New(
-		rego.Query("data.main.scale"),
-		rego.Compiler(s.Compiler),
-		rego.Input(input),
-	)
+	result := map[string]interface{}{
+		"name":          s.Name,
+		"namespace":     s.Namespace,
+		"now":           now.UnixNano(),
+		"lastScaleTime": lastScaleTime,
+		"min":           s.Min,
+		"max":           s.Max,
+		"maxStepUp":     s.MaxStepUp,
+		"maxStepDown":   s.MaxStepDown,
+		"upDelay":       int(s.UpThrottle.Seconds()),
+		"downDelay":     int(s.DownThrottle.Seconds()),
+		"interval":      s.CheckInterval,
+		"evaluations":   s.Evaluations,
+	}
 
-	query, err := r.PrepareForEval(ctx)
-	if err != nil {
-		return 0, err
+	if target != nil {
+		result["currentReplicas"] = target.Replicas
+		result["readyReplicas"] = target.ReadyReplicas
+	}
+
+	return result
+}
+
+// TargetPodResolution returns how a target's pods are found. Deployments and
+// StatefulSets follow owner references by default, and other kinds use their
+// selector, since their pods may be owned through resources agronomist
+// doesn't know about.
+func (s *ScalingPolicy) TargetPodResolution(ref TargetRef) string {
+	if s.PodResolution != "" {
+		return s.PodResolution
+	}
+	if ref.IsBuiltin() {
+		return PodResolutionOwner
+	}
+	return PodResolutionSelector
+}
+
+// ResolvePods finds the target's pods either through its label selector, as
+// Kubernetes does, or by following owner references.
+func (s *ScalingPolicy) ResolvePods(target *Target, storage *storage.Store) ([]string, error) {
+	if s.TargetPodResolution(target.Ref) == PodResolutionSelector {
+		if target.Selector == nil || target.Selector.Empty() {
+			return nil, fmt.Errorf("%s has no selector", target.Ref)
+		}
+
+		return storage.PodCache.GetPodsBySelector(s.Namespace, target.Selector)
+	}
+
+	return s.OwnedPods(target, storage)
+}
+
+func (s *ScalingPolicy) InputState() map[string]interface{} {
+	if s.State == nil {
+		return map[string]interface{}{}
 	}
-	rs, err := query.Eval(ctx)
+	return s.State
+}
+
+func (s *ScalingPolicy) Evaluate(ctx context.Context, input interface{}) (rego.ResultSet, *Decision, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if s.EvalTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.EvalTimeout)
+		defer cancel()
+	}
+
+	options := []rego.EvalOption{rego.EvalInput(input)}
+
+	var budget *BudgetTracer
+	if s.EvalBudget > 0 {
+		budget = CreateBudgetTracer(s.EvalBudget, cancel)
+		options = append(options, rego.EvalTracer(budget))
+	}
+
+	var tracer *topdown.BufferTracer
+	if s.Debug {
+		tracer = topdown.NewBufferTracer()
+		options = append(options, rego.EvalTracer(tracer))
+	}
+
+	rs, err := s.PreparedQuery.Eval(ctx, options...)
+	if tracer != nil {
+		s.LastTrace = CreateEvalTrace(*tracer, err)
+	}
+
 	if err != nil {
-		return 0, err
+		if budget != nil && budget.Exceeded() {
+			return nil, nil, &EvalTimeoutError{Policy: s.Name, Reason: fmt.Sprintf("exceeded budget of %d evaluation steps", s.EvalBudget)}
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, nil, &EvalTimeoutError{Policy: s.Name, Reason: fmt.Sprintf("exceeded timeout of %s", s.EvalTimeout)}
+		}
+		return nil, nil, err
 	}
 
 	if len(rs) < 1 {
-		return 0, fmt.Errorf("INVALID REGO RESPONSE")
+		return rs, nil, fmt.Errorf("INVALID REGO RESPONSE")
 	}
 
 	if len(rs[0].Expressions) < 1 {
-		return 0, fmt.Errorf("INVALID REGO RESPONSE")
+		return rs, nil, fmt.Errorf("INVALID REGO RESPONSE")
 	}
 
-	jsonNumber, ok := rs[0].Expressions[0].Value.(json.Number)
-	if !ok {
-		return 0, fmt.Errorf("INCORRECT RESPONSE TYPE")
+	decision, err := ParseDecision(rs[0].Expressions[0].Value)
+	if err != nil {
+		return rs, nil, err
 	}
 
-	num, err := jsonNumber.Int64()
+	err = s.CheckDecision(decision)
 	if err != nil {
-		return 0, err
+		return rs, nil, err
 	}
 
-	return int(num), nil
+	return rs, decision, nil
 }
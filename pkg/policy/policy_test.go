@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/theMagicalKarp/agronomist/pkg/storage"
+)
+
+type emptyResolver struct{}
+
+func (emptyResolver) GetRegoLibrary(namespace, name string) (*unstructured.Unstructured, bool, error) {
+	return nil, false, nil
+}
+
+func (emptyResolver) GetConfigMap(namespace, name string) (*coreV1.ConfigMap, bool, error) {
+	return nil, false, nil
+}
+
+func (emptyResolver) GetBundle(ctx context.Context, url string) (*storage.BundleEntry, error) {
+	return nil, nil
+}
+
+func (emptyResolver) GetBundleVersion(url string) string {
+	return ""
+}
+
+func createPolicyObject(spec map[string]interface{}) *unstructured.Unstructured {
+	fields := map[string]interface{}{
+		"deployment":  "foo",
+		"rego":        "package main\n\nscale = {\"replicas\": 1}\n",
+		"min":         int64(1),
+		"max":         int64(10),
+		"maxStepUp":   int64(1),
+		"maxStepDown": int64(1),
+		"upDelay":     int64(0),
+		"downDelay":   int64(0),
+		"interval":    int64(30),
+	}
+	for key, value := range spec {
+		fields[key] = value
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"namespace": "apps", "name": "foo"},
+			"spec":     fields,
+		},
+	}
+}
+
+func TestEvalLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		spec map[string]interface{}
+		err  string
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name: "zero",
+			spec: map[string]interface{}{"evalTimeout": int64(0), "evalBudget": int64(0)},
+		},
+		{
+			name: "positive",
+			spec: map[string]interface{}{"evalTimeout": int64(5), "evalBudget": int64(1000)},
+		},
+		{
+			name: "negative timeout",
+			spec: map[string]interface{}{"evalTimeout": int64(-1)},
+			err:  "`spec.evalTimeout` must not be negative",
+		},
+		{
+			name: "negative budget",
+			spec: map[string]interface{}{"evalBudget": int64(-1)},
+			err:  "`spec.evalBudget` must not be negative",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := CreateScalingPolicy(createPolicyObject(test.spec), emptyResolver{})
+
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package policy
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -10,14 +11,23 @@ import (
 )
 
 type PolicyRegistry struct {
-	Policies  map[string]*ScalingPolicy
-	CancelMap map[string]context.CancelFunc
+	StatusNamespace    string
+	EvalTimeout        time.Duration
+	EvalBudget         int64
+	ResourceNamespaces []string
+	Policies           map[string]*ScalingPolicy
+	CancelMap          map[string]context.CancelFunc
+	Histories          map[string]*History
+	Pending            map[string]*unstructured.Unstructured
 }
 
-func CreatePolicyRegistry() *PolicyRegistry {
+func CreatePolicyRegistry(statusNamespace string) *PolicyRegistry {
 	return &PolicyRegistry{
-		Policies:  make(map[string]*ScalingPolicy),
-		CancelMap: make(map[string]context.CancelFunc),
+		StatusNamespace: statusNamespace,
+		Policies:        make(map[string]*ScalingPolicy),
+		CancelMap:       make(map[string]context.CancelFunc),
+		Histories:       make(map[string]*History),
+		Pending:         make(map[string]*unstructured.Unstructured),
 	}
 }
 
@@ -25,7 +35,7 @@ func (p *PolicyRegistry) Exists(policyNamespace, policyName string) bool {
 	return p.Policies[fmt.Sprintf("%s:%s", policyNamespace, policyName)] != nil
 }
 
-func (p *PolicyRegistry) NeedsUpdate(obj *unstructured.Unstructured) bool {
+func (p *PolicyRegistry) NeedsUpdate(obj *unstructured.Unstructured, store *storage.Store) bool {
 	index := fmt.Sprintf("%s:%s", obj.GetNamespace(), obj.GetName())
 
 	storedPolicy := p.Policies[index]
@@ -33,33 +43,215 @@ func (p *PolicyRegistry) NeedsUpdate(obj *unstructured.Unstructured) bool {
 		return false
 	}
 
-	return storedPolicy.ResourceVersion != obj.GetResourceVersion()
+	if storedPolicy.ResourceVersion != obj.GetResourceVersion() {
+		return true
+	}
+
+	return SourcesChanged(storedPolicy.Sources, storedPolicy.SourceVersions, store)
 }
 
+// Update replaces a running policy. The old version keeps running until the
+// new one compiles, so a bundle that isn't fetched yet doesn't stop scaling.
 func (p *PolicyRegistry) Update(ctx context.Context, obj *unstructured.Unstructured, store *storage.Store) error {
-	index := fmt.Sprintf("%s:%s", obj.GetNamespace(), obj.GetName())
-	p.CancelMap[index]()
 	return p.Add(ctx, obj, store)
 }
 
-func (p *PolicyRegistry) Remove(policyNamespace, policyName string) {
+func (p *PolicyRegistry) Remove(policyNamespace, policyName string, store *storage.Store) {
 	index := fmt.Sprintf("%s:%s", policyNamespace, policyName)
+	storedPolicy := p.Policies[index]
+	pending := p.Pending[index]
+	p.RemoveHistories(index)
 	delete(p.Policies, index)
-	p.CancelMap[index]()
+	delete(p.Pending, index)
+	if cancel := p.CancelMap[index]; cancel != nil {
+		cancel()
+	}
 	delete(p.CancelMap, index)
+
+	if storedPolicy != nil {
+		p.ForgetBundles(storedPolicy, store)
+		ReleaseResources(storedPolicy.Watched, store)
+	}
+	if pending != nil {
+		p.ForgetURLs(BundleURLs(pending), store)
+	}
+}
+
+// WatchResources takes a reference on every resource the policy reads, so
+// informers are only started for resources that exist.
+func WatchResources(keys []storage.ResourceKey, store *storage.Store) error {
+	for i, key := range keys {
+		err := store.ResourceCache.Watch(key.GVR, key.Namespace)
+		if err != nil {
+			ReleaseResources(keys[:i], store)
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseResources drops the references taken by WatchResources, stopping
+// informers no running policy reads anymore.
+func ReleaseResources(keys []storage.ResourceKey, store *storage.Store) {
+	for _, key := range keys {
+		store.ResourceCache.Release(key.GVR, key.Namespace)
+	}
+}
+
+// ForgetBundles stops polling the bundles a policy loaded once no running
+// policy loads them anymore.
+func (p *PolicyRegistry) ForgetBundles(sp *ScalingPolicy, store *storage.Store) {
+	var urls []string
+	for _, ref := range sp.Sources {
+		if ref.Kind == "Bundle" {
+			urls = append(urls, ref.URL)
+		}
+	}
+	p.ForgetURLs(urls, store)
+}
+
+// ForgetURLs stops polling bundles that neither a running policy nor a policy
+// waiting to compile loads.
+func (p *PolicyRegistry) ForgetURLs(urls []string, store *storage.Store) {
+	for _, url := range urls {
+		if p.BundleReferenced(url) {
+			continue
+		}
+		store.BundleCache.Forget(url)
+	}
+}
+
+func (p *PolicyRegistry) BundleReferenced(url string) bool {
+	for _, sp := range p.Policies {
+		for _, ref := range sp.Sources {
+			if ref.Kind == "Bundle" && ref.URL == url {
+				return true
+			}
+		}
+	}
+	for _, obj := range p.Pending {
+		for _, pendingURL := range BundleURLs(obj) {
+			if pendingURL == url {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *PolicyRegistry) RemoveHistories(index string) {
+	storedPolicy := p.Policies[index]
+	if storedPolicy == nil {
+		return
+	}
+
+	for _, pt := range storedPolicy.Targets {
+		delete(p.Histories, HistoryKey(index, pt))
+	}
+}
+
+func HistoryKey(index string, pt *PolicyTarget) string {
+	return fmt.Sprintf("%s/%s", index, pt.Name)
 }
 
+// Add starts a policy, or replaces it if already running. A policy that fails
+// to compile is kept as pending, so the bundles it asked for keep being
+// fetched for the retry and are forgotten once it is removed.
 func (p *PolicyRegistry) Add(ctx context.Context, obj *unstructured.Unstructured, store *storage.Store) error {
 	index := fmt.Sprintf("%s:%s", obj.GetNamespace(), obj.GetName())
 
-	sp, err := CreateScalingPolicy(obj)
+	pending := p.Pending[index]
+	delete(p.Pending, index)
+
+	err := p.add(ctx, index, obj, store)
+	if err != nil {
+		p.Pending[index] = obj
+	}
+
+	if pending != nil {
+		p.ForgetURLs(BundleURLs(pending), store)
+	}
+
+	return err
+}
+
+func (p *PolicyRegistry) add(ctx context.Context, index string, obj *unstructured.Unstructured, store *storage.Store) error {
+	sp, err := CreateScalingPolicy(obj, store)
 	if err != nil {
 		return err
 	}
+	sp.StatusNamespace = p.StatusNamespace
+
+	err = sp.ResolveResourceScopes(store)
+	if err != nil {
+		return fmt.Errorf("%s Scaling Policy %v", sp.Name, err)
+	}
+
+	err = sp.CheckResourceNamespaces(p.ResourceNamespaces)
+	if err != nil {
+		return fmt.Errorf("%s Scaling Policy %v", sp.Name, err)
+	}
+
+	// an updated policy keeps the running state, which may not be recorded yet,
+	// and when it last scaled so the delays still apply
+	previous := p.Policies[index]
+	if previous != nil {
+		sp.State = previous.State
+		sp.LastRecordedState = previous.LastRecordedState
+		sp.LastScale = previous.LastScale
+	} else {
+		err = sp.LoadState(store)
+		if err != nil {
+			return err
+		}
+	}
+
+	watched, err := sp.WatchedResources(store)
+	if err != nil {
+		return fmt.Errorf("%s Scaling Policy %v", sp.Name, err)
+	}
+	err = WatchResources(watched, store)
+	if err != nil {
+		return fmt.Errorf("%s Scaling Policy %v", sp.Name, err)
+	}
+	sp.Watched = watched
+
+	if sp.EvalTimeout == 0 {
+		sp.EvalTimeout = p.EvalTimeout
+	}
+
+	if sp.EvalBudget == 0 {
+		sp.EvalBudget = p.EvalBudget
+	}
+
+	// keep collected samples across spec updates, per target
+	histories := make(map[string]*History)
+	for _, pt := range sp.Targets {
+		key := HistoryKey(index, pt)
+		if history := p.Histories[key]; history != nil {
+			history.Resize(sp.HistoryWindow)
+			pt.History = history
+		}
+		histories[key] = pt.History
+	}
+	p.RemoveHistories(index)
+	for key, history := range histories {
+		p.Histories[key] = history
+	}
+
+	if cancelPrevious := p.CancelMap[index]; cancelPrevious != nil {
+		cancelPrevious()
+	}
+
 	childCtx, cancel := context.WithCancel(ctx)
 	p.Policies[index] = sp
 	p.CancelMap[index] = cancel
 	go sp.Run(childCtx, store)
 
+	if previous != nil {
+		p.ForgetBundles(previous, store)
+		ReleaseResources(previous.Watched, store)
+	}
+
 	return nil
 }
@@ -0,0 +1,165 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/theMagicalKarp/agronomist/pkg/storage"
+)
+
+type ResourceLister interface {
+	ListResources(ctx context.Context, gvr schema.GroupVersionResource, namespace string, selector labels.Selector) ([]*unstructured.Unstructured, error)
+}
+
+type ResourceInput struct {
+	Alias     string
+	GVR       schema.GroupVersionResource
+	Namespace string
+	Selector  labels.Selector
+}
+
+func NestedResourceInputs(obj *unstructured.Unstructured) ([]ResourceInput, error) {
+	items, _, err := unstructured.NestedSlice(obj.Object, "spec", "inputs", "resources")
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []ResourceInput
+	aliases := make(map[string]bool)
+	for i, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("`spec.inputs.resources[%d]` must be an object", i)
+		}
+
+		input := ResourceInput{}
+		for _, field := range []struct {
+			key      string
+			value    *string
+			required bool
+		}{
+			{"alias", &input.Alias, true},
+			{"group", &input.GVR.Group, false},
+			{"version", &input.GVR.Version, true},
+			{"resource", &input.GVR.Resource, true},
+			{"namespace", &input.Namespace, false},
+		} {
+			value, _, err := unstructured.NestedString(entry, field.key)
+			if err != nil {
+				return nil, err
+			}
+			if value == "" && field.required {
+				return nil, fmt.Errorf("`spec.inputs.resources[%d].%s` not specified", i, field.key)
+			}
+			*field.value = value
+		}
+
+		if aliases[input.Alias] {
+			return nil, fmt.Errorf("duplicate resource input `%s`", input.Alias)
+		}
+		aliases[input.Alias] = true
+
+		// secrets are only ever read through an explicit secretRef
+		if input.GVR.Group == "" && input.GVR.Resource == "secrets" {
+			return nil, fmt.Errorf("`spec.inputs.resources[%d]` may not read secrets", i)
+		}
+
+		if input.Namespace == "" {
+			input.Namespace = obj.GetNamespace()
+		}
+
+		input.Selector, err = NestedSelector(entry, "selector")
+		if err != nil {
+			return nil, fmt.Errorf("`spec.inputs.resources[%d].selector` %v", i, err)
+		}
+
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
+
+// ResolveResourceScopes clears the namespace of resource inputs that aren't
+// namespaced, so cluster-scoped resources are watched across the cluster.
+func (s *ScalingPolicy) ResolveResourceScopes(store *storage.Store) error {
+	for i, input := range s.Resources {
+		namespaced, err := store.ResourceCache.Namespaced(input.GVR)
+		if err != nil {
+			return fmt.Errorf("resource input `%s`: %v", input.Alias, err)
+		}
+		if !namespaced {
+			s.Resources[i].Namespace = metav1.NamespaceAll
+		}
+	}
+	return nil
+}
+
+// CheckResourceNamespaces ensures every resource input reads either the
+// policy's own namespace, one the operator allowed for all policies, or a
+// cluster-scoped resource.
+func (s *ScalingPolicy) CheckResourceNamespaces(allowed []string) error {
+	for _, input := range s.Resources {
+		if input.Namespace == s.Namespace || input.Namespace == metav1.NamespaceAll {
+			continue
+		}
+
+		permitted := false
+		for _, namespace := range allowed {
+			if input.Namespace == namespace {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return fmt.Errorf("resource input `%s` may not read namespace %s", input.Alias, input.Namespace)
+		}
+	}
+	return nil
+}
+
+// WatchedResources returns the resources the policy reads through the
+// resource cache, its resource inputs and any targets that aren't builtin.
+func (s *ScalingPolicy) WatchedResources(store *storage.Store) ([]storage.ResourceKey, error) {
+	var keys []storage.ResourceKey
+	for _, input := range s.Resources {
+		keys = append(keys, storage.ResourceKey{GVR: input.GVR, Namespace: input.Namespace})
+	}
+
+	for _, pt := range s.Targets {
+		if pt.Ref.IsBuiltin() || store.RESTMapper == nil {
+			continue
+		}
+
+		mapping, err := store.RESTMapping(schema.FromAPIVersionAndKind(pt.Ref.APIVersion, pt.Ref.Kind))
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %v", pt.Ref, err)
+		}
+		keys = append(keys, storage.ResourceKey{GVR: mapping.Resource, Namespace: s.Namespace})
+	}
+
+	return keys, nil
+}
+
+// FetchResources lists the watched objects of every resource input, keyed by
+// alias.
+func (s *ScalingPolicy) FetchResources(ctx context.Context, lister ResourceLister) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, input := range s.Resources {
+		objs, err := lister.ListResources(ctx, input.GVR, input.Namespace, input.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("resource input `%s`: %v", input.Alias, err)
+		}
+
+		items := make([]interface{}, 0, len(objs))
+		for _, obj := range objs {
+			items = append(items, obj.Object)
+		}
+		result[input.Alias] = items
+	}
+	return result, nil
+}
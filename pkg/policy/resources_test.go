@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestResourceInputNamespaces(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		allowed []string
+		err     string
+	}{
+		{
+			name:  "policy namespace by default",
+			input: map[string]interface{}{"alias": "flags", "version": "v1", "resource": "configmaps"},
+		},
+		{
+			name:  "explicit policy namespace",
+			input: map[string]interface{}{"alias": "flags", "version": "v1", "resource": "configmaps", "namespace": "apps"},
+		},
+		{
+			name:    "allowed namespace",
+			input:   map[string]interface{}{"alias": "flags", "version": "v1", "resource": "configmaps", "namespace": "shared"},
+			allowed: []string{"monitoring", "shared"},
+		},
+		{
+			name:  "other namespace",
+			input: map[string]interface{}{"alias": "flags", "version": "v1", "resource": "configmaps", "namespace": "kube-system"},
+			err:   "resource input `flags` may not read namespace kube-system",
+		},
+		{
+			name:    "all namespaces",
+			input:   map[string]interface{}{"alias": "flags", "version": "v1", "resource": "configmaps", "namespace": "*"},
+			allowed: []string{"shared"},
+			err:     "may not read namespace *",
+		},
+		{
+			name:  "secrets",
+			input: map[string]interface{}{"alias": "creds", "version": "v1", "resource": "secrets"},
+			err:   "may not read secrets",
+		},
+		{
+			name:  "secrets of another group",
+			input: map[string]interface{}{"alias": "vault", "group": "vault.example.com", "version": "v1", "resource": "secrets"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{"namespace": "apps", "name": "foo"},
+					"spec": map[string]interface{}{
+						"inputs": map[string]interface{}{
+							"resources": []interface{}{test.input},
+						},
+					},
+				},
+			}
+
+			inputs, err := NestedResourceInputs(obj)
+			if err == nil {
+				sp := &ScalingPolicy{Namespace: "apps", Name: "foo", Resources: inputs}
+				err = sp.CheckResourceNamespaces(test.allowed)
+			}
+
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/theMagicalKarp/agronomist/pkg/storage"
+)
+
+type SourceResolver interface {
+	GetRegoLibrary(namespace, name string) (*unstructured.Unstructured, bool, error)
+	GetConfigMap(namespace, name string) (*coreV1.ConfigMap, bool, error)
+	GetBundle(ctx context.Context, url string) (*storage.BundleEntry, error)
+	GetBundleVersion(url string) string
+}
+
+type SourceRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+	URL       string
+}
+
+func (r SourceRef) String() string {
+	if r.Kind == "Bundle" {
+		return fmt.Sprintf("%s/%s", r.Kind, r.URL)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+func (r SourceRef) Version(resolver SourceResolver) string {
+	switch r.Kind {
+	case "RegoLibrary":
+		library, exists, err := resolver.GetRegoLibrary(r.Namespace, r.Name)
+		if err != nil || !exists {
+			return ""
+		}
+		return library.GetResourceVersion()
+	case "ConfigMap":
+		configMap, exists, err := resolver.GetConfigMap(r.Namespace, r.Name)
+		if err != nil || !exists {
+			return ""
+		}
+		return configMap.GetResourceVersion()
+	case "Bundle":
+		return resolver.GetBundleVersion(r.URL)
+	}
+
+	return ""
+}
+
+type Sources struct {
+	Modules  map[string]string
+	Data     map[string]interface{}
+	Refs     []SourceRef
+	Versions map[string]string
+}
+
+func (s *Sources) AddModules(modules map[string]string) {
+	for filename, src := range modules {
+		s.Modules[filename] = src
+	}
+}
+
+func (s *Sources) AddRef(ref SourceRef, version string) {
+	s.Refs = append(s.Refs, ref)
+	s.Versions[ref.String()] = version
+}
+
+func LoadSources(ctx context.Context, obj *unstructured.Unstructured, resolver SourceResolver) (*Sources, error) {
+	sources := &Sources{
+		Modules:  make(map[string]string),
+		Data:     make(map[string]interface{}),
+		Versions: make(map[string]string),
+	}
+
+	modules, err := NestedModules(obj, fmt.Sprintf("%s/%s", obj.GetNamespace(), obj.GetName()))
+	if err != nil {
+		return nil, err
+	}
+	sources.AddModules(modules)
+
+	libraries, _, err := unstructured.NestedStringSlice(obj.Object, "spec", "libraries")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range libraries {
+		err = sources.LoadLibrary(obj.GetNamespace(), name, resolver)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	regoFrom, _, err := unstructured.NestedSlice(obj.Object, "spec", "regoFrom")
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range regoFrom {
+		source, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("`spec.regoFrom[%d]` must be an object", i)
+		}
+
+		configMapName, hasConfigMap, err := unstructured.NestedString(source, "configMapKeyRef", "name")
+		if err != nil {
+			return nil, err
+		}
+
+		bundleURL, hasBundle, err := unstructured.NestedString(source, "bundle", "url")
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case hasConfigMap && hasBundle:
+			return nil, fmt.Errorf("`spec.regoFrom[%d]` must only set one of `configMapKeyRef` or `bundle`", i)
+		case hasConfigMap:
+			key, _, err := unstructured.NestedString(source, "configMapKeyRef", "key")
+			if err != nil {
+				return nil, err
+			}
+			err = sources.LoadConfigMap(obj.GetNamespace(), configMapName, key, resolver)
+			if err != nil {
+				return nil, err
+			}
+		case hasBundle:
+			err = sources.LoadBundle(ctx, bundleURL, resolver)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("`spec.regoFrom[%d]` must set `configMapKeyRef.name` or `bundle.url`", i)
+		}
+	}
+
+	return sources, nil
+}
+
+// BundleURLs returns the bundle urls a policy loads, ignoring malformed
+// entries which fail when the policy is compiled.
+func BundleURLs(obj *unstructured.Unstructured) []string {
+	regoFrom, _, _ := unstructured.NestedSlice(obj.Object, "spec", "regoFrom")
+
+	var urls []string
+	for _, item := range regoFrom {
+		source, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		url, exists, _ := unstructured.NestedString(source, "bundle", "url")
+		if exists {
+			urls = append(urls, url)
+		}
+	}
+
+	return urls
+}
+
+func (s *Sources) LoadLibrary(namespace, name string, resolver SourceResolver) error {
+	library, exists, err := resolver.GetRegoLibrary(namespace, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("RegoLibrary %s/%s DNE", namespace, name)
+	}
+
+	modules, err := NestedModules(library, fmt.Sprintf("%s/libraries/%s", namespace, name))
+	if err != nil {
+		return err
+	}
+	if len(modules) == 0 {
+		return fmt.Errorf("RegoLibrary %s/%s has no modules", namespace, name)
+	}
+
+	s.AddModules(modules)
+	s.AddRef(SourceRef{Kind: "RegoLibrary", Namespace: namespace, Name: name}, library.GetResourceVersion())
+	return nil
+}
+
+func (s *Sources) LoadConfigMap(namespace, name, key string, resolver SourceResolver) error {
+	configMap, exists, err := resolver.GetConfigMap(namespace, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("ConfigMap %s/%s DNE", namespace, name)
+	}
+
+	if key == "" {
+		for filename, src := range configMap.Data {
+			s.Modules[fmt.Sprintf("%s/configmaps/%s/%s", namespace, name, filename)] = src
+		}
+	} else {
+		src, ok := configMap.Data[key]
+		if !ok {
+			return fmt.Errorf("ConfigMap %s/%s has no key `%s`", namespace, name, key)
+		}
+		s.Modules[fmt.Sprintf("%s/configmaps/%s/%s", namespace, name, key)] = src
+	}
+
+	s.AddRef(SourceRef{Kind: "ConfigMap", Namespace: namespace, Name: name}, configMap.GetResourceVersion())
+	return nil
+}
+
+func (s *Sources) LoadBundle(ctx context.Context, url string, resolver SourceResolver) error {
+	entry, err := resolver.GetBundle(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	for _, module := range entry.Bundle.Modules {
+		s.Modules[fmt.Sprintf("%s/%s", url, module.Path)] = string(module.Raw)
+	}
+
+	for key, value := range entry.Bundle.Data {
+		if _, exists := s.Data[key]; exists {
+			return fmt.Errorf("bundle %s data `%s` conflicts with another bundle", url, key)
+		}
+		s.Data[key] = value
+	}
+
+	s.AddRef(SourceRef{Kind: "Bundle", URL: url}, entry.Version)
+	return nil
+}
+
+func NestedModules(obj *unstructured.Unstructured, prefix string) (map[string]string, error) {
+	modules := make(map[string]string)
+
+	regoSrc, exists, err := unstructured.NestedString(obj.Object, "spec", "rego")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		modules[fmt.Sprintf("%s.rego", prefix)] = regoSrc
+	}
+
+	extraModules, exists, err := unstructured.NestedStringMap(obj.Object, "spec", "modules")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		for name, src := range extraModules {
+			modules[fmt.Sprintf("%s/%s", prefix, name)] = src
+		}
+	}
+
+	return modules, nil
+}
+
+func SourcesChanged(refs []SourceRef, versions map[string]string, resolver SourceResolver) bool {
+	for _, ref := range refs {
+		if ref.Version(resolver) != versions[ref.String()] {
+			return true
+		}
+	}
+
+	return false
+}
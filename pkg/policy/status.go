@@ -0,0 +1,254 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+	"unicode/utf8"
+
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/topdown/lineage"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/theMagicalKarp/agronomist/pkg/storage"
+)
+
+// MaxTraceLength bounds the size of the trace and notes stored in a
+// ScalingPolicyStatus, well under the etcd object size limit.
+const MaxTraceLength = 32 * 1024
+
+type EvalTrace struct {
+	Time      time.Time
+	Notes     []string
+	Trace     string
+	Truncated bool
+	Error     string
+}
+
+func CreateEvalTrace(trace []*topdown.Event, err error) *EvalTrace {
+	evalTrace := &EvalTrace{
+		Time: time.Now().UTC(),
+	}
+
+	if err != nil {
+		evalTrace.Error = err.Error()
+	}
+
+	remaining := MaxTraceLength
+	for _, note := range lineage.Notes(trace) {
+		if note.Message == "" {
+			continue
+		}
+		if len(note.Message) > remaining {
+			evalTrace.Truncated = true
+			break
+		}
+		evalTrace.Notes = append(evalTrace.Notes, note.Message)
+		remaining -= len(note.Message)
+	}
+
+	var buf bytes.Buffer
+	topdown.PrettyTraceWithLocation(&buf, trace)
+	evalTrace.Trace = buf.String()
+
+	if len(evalTrace.Trace) > remaining {
+		// back off to a rune boundary so the status stays valid UTF-8
+		cut := remaining
+		for cut > 0 && !utf8.RuneStart(evalTrace.Trace[cut]) {
+			cut--
+		}
+		evalTrace.Trace = fmt.Sprintf("%s\n... truncated %d bytes", evalTrace.Trace[:cut], len(evalTrace.Trace)-cut)
+		evalTrace.Truncated = true
+	}
+
+	return evalTrace
+}
+
+// Equal reports whether two traces have the same content, ignoring when they
+// were taken.
+func (t *EvalTrace) Equal(other *EvalTrace) bool {
+	if other == nil {
+		return false
+	}
+	if t.Trace != other.Trace || t.Truncated != other.Truncated || t.Error != other.Error {
+		return false
+	}
+	if len(t.Notes) != len(other.Notes) {
+		return false
+	}
+	for i := range t.Notes {
+		if t.Notes[i] != other.Notes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *ScalingPolicy) PatchStatus(ctx context.Context, spec map[string]interface{}, store *storage.Store) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": spec,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = store.DynamicClientset.Resource(schema.GroupVersionResource{
+		Group:    "agronomist.io",
+		Version:  "v1",
+		Resource: "scalingpolicystatuses",
+	}).Namespace(s.StatusNamespace).Patch(ctx, s.StatusName(), k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+
+	return err
+}
+
+func (s *ScalingPolicy) StatusName() string {
+	return fmt.Sprintf("%s--%s", s.Namespace, s.Name)
+}
+
+// RecordState replaces the stored state rather than merging it, so keys the
+// policy drops from its state are dropped from the status too. It only patches
+// when the state differs from the last one recorded.
+func (s *ScalingPolicy) RecordState(ctx context.Context, store *storage.Store) error {
+	state := s.InputState()
+	if s.LastRecordedState != nil && reflect.DeepEqual(state, s.LastRecordedState) {
+		return nil
+	}
+
+	patch, err := json.Marshal([]map[string]interface{}{
+		{
+			"op":    "add",
+			"path":  "/spec/state",
+			"value": state,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = store.DynamicClientset.Resource(schema.GroupVersionResource{
+		Group:    "agronomist.io",
+		Version:  "v1",
+		Resource: "scalingpolicystatuses",
+	}).Namespace(s.StatusNamespace).Patch(ctx, s.StatusName(), k8stypes.JSONPatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+
+	s.LastRecordedState = state
+	return nil
+}
+
+// LoadState restores the state persisted by whichever agronomist replica ran
+// this policy last.
+func (s *ScalingPolicy) LoadState(store *storage.Store) error {
+	status, exists, err := store.ScalingPolicyStatusCache.GetScalingPolicy(s.StatusNamespace, s.StatusName())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	state, exists, err := unstructured.NestedMap(status.Object, "spec", "state")
+	if err != nil {
+		return err
+	}
+	if exists {
+		s.State = state
+	}
+
+	s.LastRecordedState = s.InputState()
+	return nil
+}
+
+// RecordDecision writes the decision to the status, only patching when it
+// differs from the last one recorded by this policy, so `time` is when the
+// policy last changed its mind.
+func (s *ScalingPolicy) RecordDecision(ctx context.Context, decision *Decision, store *storage.Store) error {
+	status := map[string]interface{}{
+		"replicas":   decision.Replicas,
+		"reason":     decision.Reason,
+		"confidence": decision.Confidence,
+		"labels":     decision.Labels,
+	}
+
+	if decision.Targets != nil {
+		status["replicas"] = nil
+		status["targets"] = decision.Targets
+	}
+
+	if reflect.DeepEqual(status, s.LastDecision) {
+		return nil
+	}
+
+	patch := map[string]interface{}{
+		"time": time.Now().UTC().Format(time.RFC3339),
+	}
+	for key, value := range status {
+		patch[key] = value
+	}
+
+	err := s.PatchStatus(ctx, map[string]interface{}{
+		"decision": patch,
+	}, store)
+	if err != nil {
+		return err
+	}
+
+	s.LastDecision = status
+	return nil
+}
+
+// RecordError writes the latest evaluation error to the status, only patching
+// when it differs from the last one recorded by this policy.
+func (s *ScalingPolicy) RecordError(ctx context.Context, err error, store *storage.Store) error {
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+
+	if s.LastError != nil && *s.LastError == message {
+		return nil
+	}
+
+	statusErr := s.PatchStatus(ctx, map[string]interface{}{
+		"error": message,
+	}, store)
+	if statusErr != nil {
+		return statusErr
+	}
+
+	s.LastError = &message
+	return nil
+}
+
+// RecordTrace writes the latest trace to the status, only patching when it
+// differs from the last one recorded by this policy.
+func (s *ScalingPolicy) RecordTrace(ctx context.Context, trace *EvalTrace, store *storage.Store) error {
+	if trace.Equal(s.LastRecordedTrace) {
+		return nil
+	}
+
+	err := s.PatchStatus(ctx, map[string]interface{}{
+		"debug": map[string]interface{}{
+			"time":      trace.Time.Format(time.RFC3339),
+			"notes":     trace.Notes,
+			"trace":     trace.Trace,
+			"truncated": trace.Truncated,
+			"error":     trace.Error,
+		},
+	}, store)
+	if err != nil {
+		return err
+	}
+
+	s.LastRecordedTrace = trace
+	return nil
+}
@@ -0,0 +1,329 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	appsV1 "k8s.io/api/apps/v1"
+	autoscalingV1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/theMagicalKarp/agronomist/pkg/storage"
+)
+
+const (
+	KindDeployment  = "Deployment"
+	KindStatefulSet = "StatefulSet"
+)
+
+type TargetRef struct {
+	APIVersion string
+	Kind       string
+	Name       string
+}
+
+func (t TargetRef) String() string {
+	return fmt.Sprintf("%s/%s", t.Kind, t.Name)
+}
+
+// IsBuiltin reports whether the target is read from the typed informer caches
+// rather than through the generic scale subresource.
+func (t TargetRef) IsBuiltin() bool {
+	return t.APIVersion == "apps/v1" && (t.Kind == KindDeployment || t.Kind == KindStatefulSet)
+}
+
+// Target is a snapshot of the workload a policy scales.
+type Target struct {
+	Ref             TargetRef
+	Object          interface{}
+	Namespace       string
+	UID             types.UID
+	ResourceVersion string
+	Replicas        int
+	ReadyReplicas   int
+	Selector        labels.Selector
+	Resource        schema.GroupResource
+	Scale           *autoscalingV1.Scale
+}
+
+// NestedTargetRef reads `spec.targetRef`, falling back to the older
+// `spec.deployment` name.
+func NestedTargetRef(obj *unstructured.Unstructured) (TargetRef, error) {
+	targetRef, exists, err := unstructured.NestedMap(obj.Object, "spec", "targetRef")
+	if err != nil {
+		return TargetRef{}, err
+	}
+
+	if !exists {
+		deployment, exists, err := unstructured.NestedString(obj.Object, "spec", "deployment")
+		if err != nil {
+			return TargetRef{}, err
+		}
+		if !exists || deployment == "" {
+			return TargetRef{}, fmt.Errorf("`spec.targetRef` not specified")
+		}
+		return TargetRef{APIVersion: "apps/v1", Kind: KindDeployment, Name: deployment}, nil
+	}
+
+	return ParseTargetRef(targetRef, "spec.targetRef")
+}
+
+// ParseTargetRef reads the apiVersion, kind and name of a target reference
+// found at path.
+func ParseTargetRef(obj map[string]interface{}, path string) (TargetRef, error) {
+	ref := TargetRef{}
+
+	for _, field := range []struct {
+		key   string
+		value *string
+	}{
+		{"apiVersion", &ref.APIVersion},
+		{"kind", &ref.Kind},
+		{"name", &ref.Name},
+	} {
+		value, _, err := unstructured.NestedString(obj, field.key)
+		if err != nil {
+			return ref, err
+		}
+		if value == "" {
+			return ref, fmt.Errorf("`%s.%s` not specified", path, field.key)
+		}
+		*field.value = value
+	}
+
+	_, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return ref, fmt.Errorf("`%s.apiVersion` %v", path, err)
+	}
+
+	return ref, nil
+}
+
+func TargetSelector(selector *metav1.LabelSelector) labels.Selector {
+	if selector == nil {
+		return nil
+	}
+
+	result, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+func CreateDeploymentTarget(ref TargetRef, deployment *appsV1.Deployment) *Target {
+	replicas := 1
+	if deployment.Spec.Replicas != nil {
+		replicas = int(*deployment.Spec.Replicas)
+	}
+
+	return &Target{
+		Ref:             ref,
+		Object:          deployment,
+		Namespace:       deployment.Namespace,
+		UID:             deployment.UID,
+		ResourceVersion: deployment.ResourceVersion,
+		Replicas:        replicas,
+		ReadyReplicas:   int(deployment.Status.ReadyReplicas),
+		Selector:        TargetSelector(deployment.Spec.Selector),
+		Resource:        schema.GroupResource{Group: "apps", Resource: "deployments"},
+	}
+}
+
+func CreateStatefulSetTarget(ref TargetRef, statefulSet *appsV1.StatefulSet) *Target {
+	replicas := 1
+	if statefulSet.Spec.Replicas != nil {
+		replicas = int(*statefulSet.Spec.Replicas)
+	}
+
+	return &Target{
+		Ref:             ref,
+		Object:          statefulSet,
+		Namespace:       statefulSet.Namespace,
+		UID:             statefulSet.UID,
+		ResourceVersion: statefulSet.ResourceVersion,
+		Replicas:        replicas,
+		ReadyReplicas:   int(statefulSet.Status.ReadyReplicas),
+		Selector:        TargetSelector(statefulSet.Spec.Selector),
+		Resource:        schema.GroupResource{Group: "apps", Resource: "statefulsets"},
+	}
+}
+
+// CreateScaleTarget builds a target from its scale subresource. The object
+// itself is only used for input.target and falls back to the Scale.
+func CreateScaleTarget(ref TargetRef, resource schema.GroupResource, scale *autoscalingV1.Scale, obj *unstructured.Unstructured) (*Target, error) {
+	selector, err := labels.Parse(scale.Status.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("%s has an invalid selector: %v", ref, err)
+	}
+
+	target := &Target{
+		Ref:             ref,
+		Object:          scale,
+		Namespace:       scale.Namespace,
+		UID:             scale.UID,
+		ResourceVersion: scale.ResourceVersion,
+		Replicas:        int(scale.Spec.Replicas),
+		Selector:        selector,
+		Resource:        resource,
+		Scale:           scale,
+	}
+
+	if obj != nil {
+		target.Object = obj.Object
+		target.UID = obj.GetUID()
+
+		readyReplicas, exists, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		if exists {
+			target.ReadyReplicas = int(readyReplicas)
+		}
+	}
+
+	return target, nil
+}
+
+// GetTarget looks a target up in the informer caches, or through discovery
+// and the scale subresource for any other kind.
+func (s *ScalingPolicy) GetTarget(ctx context.Context, ref TargetRef, store *storage.Store) (*Target, bool, error) {
+	if !ref.IsBuiltin() {
+		return s.GetScaleTarget(ctx, ref, store)
+	}
+
+	switch ref.Kind {
+	case KindStatefulSet:
+		statefulSet, exists, err := store.StatefulSetCache.GetStatefulSet(s.Namespace, ref.Name)
+		if err != nil || !exists {
+			return nil, exists, err
+		}
+		return CreateStatefulSetTarget(ref, statefulSet), true, nil
+	default:
+		deployment, exists, err := store.DeploymentCache.GetDeployment(s.Namespace, ref.Name)
+		if err != nil || !exists {
+			return nil, exists, err
+		}
+		return CreateDeploymentTarget(ref, deployment), true, nil
+	}
+}
+
+func (s *ScalingPolicy) GetScaleTarget(ctx context.Context, ref TargetRef, store *storage.Store) (*Target, bool, error) {
+	if store.RESTMapper == nil || store.ScaleClient == nil {
+		return nil, false, fmt.Errorf("scale client not configured")
+	}
+
+	mapping, err := store.RESTMapping(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+	if err != nil {
+		return nil, false, err
+	}
+
+	resource := mapping.Resource.GroupResource()
+	scale, err := store.ScaleClient.Scales(s.Namespace).Get(ctx, resource, ref.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	obj, _, err := store.GetResource(ctx, mapping.Resource, s.Namespace, ref.Name)
+	if err != nil {
+		fmt.Printf("Failed to read %s %s/%s: %v\n", ref.Kind, s.Namespace, ref.Name, err)
+	}
+
+	target, err := CreateScaleTarget(ref, resource, scale, obj)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return target, true, nil
+}
+
+// GetTargets looks up every target of the policy, keyed by target name.
+func (s *ScalingPolicy) GetTargets(ctx context.Context, store *storage.Store) (map[string]*Target, error) {
+	targets := make(map[string]*Target)
+
+	for _, pt := range s.Targets {
+		target, exists, err := s.GetTarget(ctx, pt.Ref, store)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, fmt.Errorf("%s DNE %s/%s", pt.Ref.Kind, s.Namespace, pt.Ref.Name)
+		}
+
+		targets[pt.Name] = target
+	}
+
+	return targets, nil
+}
+
+// UpdateScale sets the target's replicas through its scale subresource.
+func (s *ScalingPolicy) UpdateScale(ctx context.Context, target *Target, replicas int, store *storage.Store) error {
+	switch {
+	case !target.Ref.IsBuiltin():
+		client := store.ScaleClient.Scales(s.Namespace)
+
+		scale, err := client.Get(ctx, target.Resource, target.Ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		scale.Spec.Replicas = int32(replicas)
+
+		_, err = client.Update(ctx, target.Resource, scale, metav1.UpdateOptions{})
+		return err
+	case target.Ref.Kind == KindStatefulSet:
+		client := store.ClientSet.AppsV1().StatefulSets(s.Namespace)
+
+		scale, err := client.GetScale(ctx, target.Ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		scale.Spec.Replicas = int32(replicas)
+
+		_, err = client.UpdateScale(ctx, target.Ref.Name, scale, metav1.UpdateOptions{})
+		return err
+	default:
+		client := store.ClientSet.AppsV1().Deployments(s.Namespace)
+
+		scale, err := client.GetScale(ctx, target.Ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		scale.Spec.Replicas = int32(replicas)
+
+		_, err = client.UpdateScale(ctx, target.Ref.Name, scale, metav1.UpdateOptions{})
+		return err
+	}
+}
+
+// OwnedPods follows owner references from the target to its pods, both
+// directly and through any ReplicaSets it owns, as Deployments and Argo
+// Rollouts do.
+func (s *ScalingPolicy) OwnedPods(target *Target, store *storage.Store) ([]string, error) {
+	podNames := store.PodCache.GetPodsByOwnerUID(target.UID)
+
+	for _, replicaSet := range store.ReplicaSetCache.GetReplicaSetsByOwnerUID(target.UID) {
+		rs, exists, err := store.ReplicaSetCache.GetReplicaSet(s.Namespace, replicaSet)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			fmt.Println("? Replicaset DNE ?")
+			continue
+		}
+
+		podNames = append(podNames, store.PodCache.GetPodsByOwnerUID(rs.UID)...)
+	}
+
+	return podNames, nil
+}
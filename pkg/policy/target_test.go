@@ -0,0 +1,64 @@
+package policy
+
+import (
+	"testing"
+
+	autoscalingV1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCreateScaleTarget(t *testing.T) {
+	ref := TargetRef{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "web"}
+	resource := schema.GroupResource{Group: "argoproj.io", Resource: "rollouts"}
+	scale := &autoscalingV1.Scale{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "web", UID: "scale-uid"},
+		Spec:       autoscalingV1.ScaleSpec{Replicas: 4},
+		Status:     autoscalingV1.ScaleStatus{Replicas: 3, Selector: "app=web"},
+	}
+
+	rollout := func(status map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "argoproj.io/v1alpha1",
+				"kind":       "Rollout",
+				"metadata":   map[string]interface{}{"namespace": "apps", "name": "web", "uid": "rollout-uid"},
+				"status":     status,
+			},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		obj   *unstructured.Unstructured
+		uid   string
+		ready int
+	}{
+		{"without the object", nil, "scale-uid", 0},
+		{"without readyReplicas", rollout(map[string]interface{}{"replicas": int64(3)}), "rollout-uid", 0},
+		{"with readyReplicas", rollout(map[string]interface{}{"readyReplicas": int64(2)}), "rollout-uid", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target, err := CreateScaleTarget(ref, resource, scale, test.obj)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if target.Replicas != 4 {
+				t.Fatalf("expected 4 replicas, got %d", target.Replicas)
+			}
+			if target.ReadyReplicas != test.ready {
+				t.Fatalf("expected %d ready replicas, got %d", test.ready, target.ReadyReplicas)
+			}
+			if string(target.UID) != test.uid {
+				t.Fatalf("expected uid %s, got %s", test.uid, target.UID)
+			}
+			if target.Selector.String() != "app=web" {
+				t.Fatalf("expected selector app=web, got %s", target.Selector)
+			}
+		})
+	}
+}
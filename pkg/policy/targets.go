@@ -0,0 +1,316 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+
+	"github.com/theMagicalKarp/agronomist/pkg/storage"
+)
+
+// PolicyTarget is one of the workloads a policy scales along with its limits.
+// A policy with a single `spec.targetRef` has one target without a name.
+type PolicyTarget struct {
+	Name        string
+	Ref         TargetRef
+	Min         int
+	Max         int
+	MaxStepUp   int
+	MaxStepDown int
+	History     *History
+}
+
+// TargetInput is what a policy sees of one of its targets on an evaluation.
+type TargetInput struct {
+	Target       *Target
+	Pods         []*coreV1.Pod
+	PodMetrics   []*metricsv1beta1.PodMetrics
+	ExcludedPods []ExcludedPod
+}
+
+// NestedTargets reads `spec.targets`, or the single `spec.targetRef` when it
+// is not set. Limits missing from a target default to the policy's.
+func NestedTargets(obj *unstructured.Unstructured, defaults PolicyTarget, historyWindow int) ([]*PolicyTarget, error) {
+	items, exists, err := unstructured.NestedSlice(obj.Object, "spec", "targets")
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		ref, err := NestedTargetRef(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		target := defaults
+		target.Ref = ref
+		target.History = CreateHistory(historyWindow)
+		return []*PolicyTarget{&target}, nil
+	}
+
+	for _, field := range []string{"targetRef", "deployment"} {
+		_, exists, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", field)
+		if exists {
+			return nil, fmt.Errorf("`spec.targets` and `spec.%s` are mutually exclusive", field)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("`spec.targets` must not be empty")
+	}
+
+	var targets []*PolicyTarget
+	names := make(map[string]bool)
+	for i, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("`spec.targets[%d]` must be an object", i)
+		}
+
+		name, _, err := unstructured.NestedString(entry, "name")
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return nil, fmt.Errorf("`spec.targets[%d].name` not specified", i)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate target `%s`", name)
+		}
+		names[name] = true
+
+		targetRef, exists, err := unstructured.NestedMap(entry, "targetRef")
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("`spec.targets[%d].targetRef` not specified", i)
+		}
+
+		ref, err := ParseTargetRef(targetRef, fmt.Sprintf("spec.targets[%d].targetRef", i))
+		if err != nil {
+			return nil, err
+		}
+
+		target := defaults
+		target.Name = name
+		target.Ref = ref
+		target.History = CreateHistory(historyWindow)
+
+		for _, limit := range []struct {
+			key   string
+			value *int
+		}{
+			{"min", &target.Min},
+			{"max", &target.Max},
+			{"maxStepUp", &target.MaxStepUp},
+			{"maxStepDown", &target.MaxStepDown},
+		} {
+			value, exists, err := unstructured.NestedInt64(entry, limit.key)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				*limit.value = int(value)
+			}
+		}
+
+		targets = append(targets, &target)
+	}
+
+	return targets, nil
+}
+
+// MultiTarget reports whether the policy names its targets through
+// `spec.targets`, in which case rego sees them under `input.targets` and
+// decides replicas for each by name.
+func (s *ScalingPolicy) MultiTarget() bool {
+	return len(s.Targets) > 0 && s.Targets[0].Name != ""
+}
+
+func (s *ScalingPolicy) FindTarget(name string) *PolicyTarget {
+	for _, pt := range s.Targets {
+		if pt.Name == name {
+			return pt
+		}
+	}
+	return nil
+}
+
+// CheckDecision makes sure a decision matches the policy's targets, a replica
+// count for a single target or one for every named target.
+func (s *ScalingPolicy) CheckDecision(decision *Decision) error {
+	if !s.MultiTarget() {
+		if decision.Targets != nil {
+			return fmt.Errorf("decision names targets but the policy has a single target")
+		}
+		return nil
+	}
+
+	if decision.Targets == nil {
+		return fmt.Errorf("decision must map each target to a replica count")
+	}
+
+	for _, pt := range s.Targets {
+		if _, exists := decision.Targets[pt.Name]; !exists {
+			return fmt.Errorf("decision missing replicas for target `%s`", pt.Name)
+		}
+	}
+
+	if len(decision.Targets) != len(s.Targets) {
+		for name := range decision.Targets {
+			if s.FindTarget(name) == nil {
+				return fmt.Errorf("decision names unknown target `%s`", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *PolicyTarget) Normalize(scale, replicas int) int {
+	if scale < t.Min {
+		scale = t.Min
+	}
+
+	if scale > t.Max {
+		scale = t.Max
+	}
+
+	// going up
+	if scale > replicas {
+		if scale-replicas > t.MaxStepUp {
+			return replicas + t.MaxStepUp
+		}
+		return scale
+	}
+
+	// going down
+	if scale < replicas {
+		if replicas-scale > t.MaxStepDown {
+			return replicas - t.MaxStepDown
+		}
+		return scale
+	}
+
+	return scale
+}
+
+// Plan normalizes the decision for every target and applies the up/down
+// throttles as of now. Targets scale together, so a throttle on any of them
+// holds them all. A non-empty hold explains why the current replica counts
+// should be kept.
+func (s *ScalingPolicy) Plan(decision *Decision, replicas map[string]int, now time.Time) (map[string]int, string) {
+	scales := make(map[string]int)
+	changed := false
+
+	for _, pt := range s.Targets {
+		current := replicas[pt.Name]
+		scale := pt.Normalize(decision.TargetReplicas(pt.Name), current)
+		scales[pt.Name] = scale
+
+		hold := ""
+		switch {
+		case scale > current && now.Sub(s.LastScale) < s.UpThrottle:
+			hold = "TOO SOON UP!"
+		case scale < current && now.Sub(s.LastScale) < s.DownThrottle:
+			hold = "TOO SOON DOWN!"
+		}
+
+		if hold != "" {
+			if pt.Name != "" {
+				hold = fmt.Sprintf("%s %s", pt.Name, hold)
+			}
+			return replicas, hold
+		}
+
+		if scale != current {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return replicas, "nothing to do"
+	}
+
+	return scales, ""
+}
+
+// ApplyScales updates every target whose replicas changed. If an update
+// fails the targets already updated are scaled back, so the group is never
+// left partially scaled.
+func (s *ScalingPolicy) ApplyScales(ctx context.Context, targets map[string]*Target, scales map[string]int, decision *Decision, store *storage.Store) error {
+	var applied []*Target
+
+	for _, pt := range s.Targets {
+		target := targets[pt.Name]
+		scale := scales[pt.Name]
+		if scale == target.Replicas {
+			continue
+		}
+
+		fmt.Printf("scaling %s %s/%s to %d, policy decided %s\n", target.Ref.Kind, s.Namespace, target.Ref.Name, scale, decision)
+
+		err := s.UpdateScale(ctx, target, scale, store)
+		if err != nil {
+			for i := len(applied) - 1; i >= 0; i-- {
+				fmt.Printf("rolling back %s %s/%s to %d\n", applied[i].Ref.Kind, s.Namespace, applied[i].Ref.Name, applied[i].Replicas)
+
+				rollbackErr := s.UpdateScale(ctx, applied[i], applied[i].Replicas, store)
+				if rollbackErr != nil {
+					fmt.Printf("Failed to roll back %s %s/%s: %v\n", applied[i].Ref.Kind, s.Namespace, applied[i].Ref.Name, rollbackErr)
+				}
+			}
+			return fmt.Errorf("%s Scaling Policy failed to scale %s: %v", s.Name, target.Ref, err)
+		}
+
+		applied = append(applied, target)
+	}
+
+	return nil
+}
+
+// InputTargets lays the targets out as rego sees them, at the top of the
+// input for a single target or by name under `targets`, and records each
+// target's history sample.
+func (s *ScalingPolicy) InputTargets(now time.Time, inputs map[string]*TargetInput) map[string]interface{} {
+	result := make(map[string]interface{})
+	targets := make(map[string]interface{})
+
+	for _, pt := range s.Targets {
+		input := inputs[pt.Name]
+		pt.History.Add(CreateHistoryEntry(now, input.PodMetrics))
+
+		if !s.MultiTarget() {
+			result["podMetrics"] = input.PodMetrics
+			result["target"] = input.Target.Object
+			result["pods"] = input.Pods
+			result["excludedPods"] = input.ExcludedPods
+			result["history"] = pt.History.List()
+			result["agronomist"] = s.InputContext(now, input.Target)
+			return result
+		}
+
+		targets[pt.Name] = map[string]interface{}{
+			"podMetrics":      input.PodMetrics,
+			"target":          input.Target.Object,
+			"pods":            input.Pods,
+			"excludedPods":    input.ExcludedPods,
+			"history":         pt.History.List(),
+			"min":             pt.Min,
+			"max":             pt.Max,
+			"maxStepUp":       pt.MaxStepUp,
+			"maxStepDown":     pt.MaxStepDown,
+			"currentReplicas": input.Target.Replicas,
+			"readyReplicas":   input.Target.ReadyReplicas,
+		}
+	}
+
+	result["targets"] = targets
+	result["agronomist"] = s.InputContext(now, nil)
+	return result
+}
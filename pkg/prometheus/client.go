@@ -0,0 +1,181 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	HTTP     *http.Client
+	Address  string
+	Token    string
+	Username string
+	Password string
+}
+
+type Response struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+type Series struct {
+	Metric map[string]string `json:"metric"`
+	Value  []interface{}     `json:"value"`
+	Values [][]interface{}   `json:"values"`
+}
+
+func CreateClient(client *http.Client, address string) *Client {
+	return &Client{
+		HTTP:    client,
+		Address: strings.TrimSuffix(address, "/"),
+	}
+}
+
+// Query runs an instant query and converts the result into plain values for
+// the policy input. Vectors become lists of {metric, value, timestamp},
+// matrices lists of {metric, values} and scalars and strings bare values.
+func (c *Client) Query(ctx context.Context, query string, now time.Time) (interface{}, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", strconv.FormatFloat(float64(now.UnixNano())/1e9, 'f', -1, 64))
+
+	request, err := http.NewRequest("POST", c.Address+"/api/v1/query", strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if c.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.Username != "" {
+		request.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err := c.HTTP.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &Response{}
+	err = json.Unmarshal(body, response)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected response (%s) from %s", resp.Status, c.Address)
+	}
+
+	if response.Status != "success" {
+		return nil, fmt.Errorf("query failed (%s): %s", response.ErrorType, response.Error)
+	}
+
+	return ParseResult(response.Data.ResultType, response.Data.Result)
+}
+
+func ParseResult(resultType string, raw json.RawMessage) (interface{}, error) {
+	switch resultType {
+	case "scalar", "string":
+		var sample []interface{}
+		err := json.Unmarshal(raw, &sample)
+		if err != nil {
+			return nil, err
+		}
+		_, value, err := ParseSample(sample)
+		if err != nil {
+			return nil, err
+		}
+		if resultType == "string" {
+			return sample[1], nil
+		}
+		return value, nil
+	case "vector", "matrix":
+		var series []Series
+		err := json.Unmarshal(raw, &series)
+		if err != nil {
+			return nil, err
+		}
+
+		result := make([]interface{}, 0, len(series))
+		for _, s := range series {
+			metric := s.Metric
+			if metric == nil {
+				metric = map[string]string{}
+			}
+
+			if resultType == "vector" {
+				timestamp, value, err := ParseSample(s.Value)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, map[string]interface{}{
+					"metric":    metric,
+					"value":     value,
+					"timestamp": timestamp,
+				})
+				continue
+			}
+
+			values := make([]interface{}, 0, len(s.Values))
+			for _, sample := range s.Values {
+				timestamp, value, err := ParseSample(sample)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, []interface{}{timestamp, value})
+			}
+			result = append(result, map[string]interface{}{
+				"metric": metric,
+				"values": values,
+			})
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("unknown result type `%s`", resultType)
+}
+
+// ParseSample reads a [timestamp, "value"] pair. NaN and infinite values have
+// no JSON representation and are returned as nil.
+func ParseSample(sample []interface{}) (float64, interface{}, error) {
+	if len(sample) != 2 {
+		return 0, nil, fmt.Errorf("invalid sample %v", sample)
+	}
+
+	timestamp, ok := sample[0].(float64)
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid sample timestamp %v", sample[0])
+	}
+
+	raw, ok := sample[1].(string)
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid sample value %v", sample[1])
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return timestamp, raw, nil
+	}
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return timestamp, nil, nil
+	}
+
+	return timestamp, value, nil
+}
@@ -34,7 +34,7 @@ func CreateScalingPolicyReconciler(ownerNamespace, ownerName string, ownerUID ty
 
 		Interval: 1,
 
-		PolicyRegistry: policy.CreatePolicyRegistry(),
+		PolicyRegistry: policy.CreatePolicyRegistry(ownerNamespace),
 
 		Store: store,
 	}
@@ -125,9 +125,10 @@ func (s *ScalingPolicyReconciler) AttemptClaims(ctx context.Context) error {
 				return err
 			}
 
+			// retried on the next tick, e.g. once its bundles are fetched
 			err = s.PolicyRegistry.Add(ctx, scalingPolicy, s.Store)
 			if err != nil {
-				return err
+				fmt.Println(err)
 			}
 		}
 	}
@@ -140,13 +141,13 @@ func (s *ScalingPolicyReconciler) UpdateClaims(ctx context.Context) error {
 	for _, item := range s.Store.ScalingPolicyCache.ListScalingPolicies() {
 		scalingPolicy := item.(*unstructured.Unstructured)
 
-		if !s.PolicyRegistry.NeedsUpdate(scalingPolicy) {
+		if !s.PolicyRegistry.NeedsUpdate(scalingPolicy, s.Store) {
 			continue
 		}
 
 		err := s.PolicyRegistry.Update(ctx, scalingPolicy, s.Store)
 		if err != nil {
-			return err
+			fmt.Println(err)
 		}
 	}
 
@@ -205,7 +206,15 @@ func (s *ScalingPolicyReconciler) Cleanup(ctx context.Context) error {
 			continue
 		}
 
-		s.PolicyRegistry.Remove(storedPolicy.Namespace, storedPolicy.Name)
+		s.PolicyRegistry.Remove(storedPolicy.Namespace, storedPolicy.Name, s.Store)
+	}
+
+	for _, pending := range s.PolicyRegistry.Pending {
+		if ownedPolicyStatuses[fmt.Sprintf("%s--%s", pending.GetNamespace(), pending.GetName())] {
+			continue
+		}
+
+		s.PolicyRegistry.Remove(pending.GetNamespace(), pending.GetName(), s.Store)
 	}
 
 	return nil
@@ -0,0 +1,123 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+func MaxReplicas(steps []Step) int {
+	max := 0
+	for _, step := range steps {
+		if step.From > max {
+			max = step.From
+		}
+		if step.To > max {
+			max = step.To
+		}
+	}
+	return max
+}
+
+// RenderASCII plots replicas over time, one column per step.
+func RenderASCII(w io.Writer, steps []Step) error {
+	height := MaxReplicas(steps)
+	labelWidth := len(fmt.Sprintf("%d", height))
+
+	for row := height; row > 0; row-- {
+		var line strings.Builder
+		for _, step := range steps {
+			switch {
+			case step.Err != nil:
+				line.WriteByte('!')
+			case step.To >= row:
+				line.WriteByte('#')
+			default:
+				line.WriteByte(' ')
+			}
+		}
+
+		_, err := fmt.Fprintf(w, "%*d |%s\n", labelWidth, row, strings.TrimRight(line.String(), " "))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "%s +%s\n", strings.Repeat(" ", labelWidth), strings.Repeat("-", len(steps)))
+	if err != nil {
+		return err
+	}
+
+	if len(steps) > 0 {
+		_, err = fmt.Fprintf(w, "%s  %vs .. %vs\n", strings.Repeat(" ", labelWidth), steps[0].Time.Seconds(), steps[len(steps)-1].Time.Seconds())
+	}
+
+	return err
+}
+
+func RenderSVG(w io.Writer, steps []Step) error {
+	const (
+		width   = 800
+		height  = 300
+		padding = 40
+	)
+
+	maxReplicas := MaxReplicas(steps)
+	if maxReplicas == 0 {
+		maxReplicas = 1
+	}
+
+	var duration float64
+	if len(steps) > 0 {
+		duration = steps[len(steps)-1].Time.Seconds()
+	}
+	if duration == 0 {
+		duration = 1
+	}
+
+	x := func(seconds float64) float64 {
+		return padding + seconds/duration*(width-2*padding)
+	}
+	y := func(replicas int) float64 {
+		return height - padding - float64(replicas)/float64(maxReplicas)*(height-2*padding)
+	}
+
+	var points []string
+	for i, step := range steps {
+		seconds := step.Time.Seconds()
+		if i > 0 {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(seconds), y(steps[i-1].To)))
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x(seconds), y(step.To)))
+	}
+
+	var errorMarks strings.Builder
+	for _, step := range steps {
+		if step.Err == nil {
+			continue
+		}
+		fmt.Fprintf(&errorMarks, "  <circle cx=\"%.1f\" cy=\"%.1f\" r=\"3\" fill=\"#d62728\"/>\n", x(step.Time.Seconds()), y(step.To))
+	}
+
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">
+  <rect width="100%%" height="100%%" fill="white"/>
+  <line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>
+  <line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>
+  <text x="%d" y="%d" font-family="sans-serif" font-size="12" text-anchor="end">%d</text>
+  <text x="%d" y="%d" font-family="sans-serif" font-size="12" text-anchor="end">0</text>
+  <text x="%d" y="%d" font-family="sans-serif" font-size="12" text-anchor="end">%vs</text>
+  <polyline fill="none" stroke="#1f77b4" stroke-width="2" points="%s"/>
+%s</svg>
+`,
+		width, height, width, height,
+		padding, padding, padding, height-padding,
+		padding, height-padding, width-padding, height-padding,
+		padding-4, padding+4, maxReplicas,
+		padding-4, height-padding+4,
+		width-padding, height-padding+16, duration,
+		strings.Join(points, " "),
+		errorMarks.String(),
+	)
+
+	return err
+}
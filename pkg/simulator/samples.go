@@ -0,0 +1,129 @@
+package simulator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+type Sample struct {
+	Time   float64 `json:"time"`
+	CPU    string  `json:"cpu"`
+	Memory string  `json:"memory"`
+}
+
+func ReadSamples(path string) ([]Sample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var samples []Sample
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		samples, err = ParseCSVSamples(file)
+	} else {
+		samples, err = ParseJSONSamples(file)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%s: no samples", path)
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Time < samples[j].Time
+	})
+
+	return samples, nil
+}
+
+// ParseCSVSamples reads samples from a CSV with a header row naming the
+// `time`, `cpu` and optionally `memory` columns.
+func ParseCSVSamples(r io.Reader) ([]Sample, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	timeColumn, ok := columns["time"]
+	if !ok {
+		return nil, fmt.Errorf("missing `time` column")
+	}
+
+	cpuColumn, ok := columns["cpu"]
+	if !ok {
+		return nil, fmt.Errorf("missing `cpu` column")
+	}
+
+	memoryColumn, hasMemory := columns["memory"]
+
+	var samples []Sample
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := strconv.ParseFloat(record[timeColumn], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid time %q", line, record[timeColumn])
+		}
+
+		sample := Sample{
+			Time: t,
+			CPU:  record[cpuColumn],
+		}
+
+		if hasMemory {
+			sample.Memory = record[memoryColumn]
+		}
+
+		samples = append(samples, sample)
+	}
+
+	return samples, nil
+}
+
+func ParseJSONSamples(r io.Reader) ([]Sample, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonRaw, err := yaml.ToJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []Sample
+	err = json.Unmarshal(jsonRaw, &samples)
+	if err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
@@ -0,0 +1,295 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsV1 "k8s.io/api/apps/v1"
+	autoscalingV1 "k8s.io/api/autoscaling/v1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+
+	"github.com/theMagicalKarp/agronomist/pkg/policy"
+)
+
+const (
+	LoadPerPod = "per-pod"
+	LoadTotal  = "total"
+)
+
+type Simulation struct {
+	Policy    *policy.ScalingPolicy
+	Replicas  int
+	LoadModel string
+	Resources coreV1.ResourceRequirements
+	Start     time.Time
+}
+
+// Step is one tick of the simulation. From and To are totals across the
+// policy's targets, and Targets holds the replicas of each named target.
+type Step struct {
+	Time     time.Duration
+	Sample   Sample
+	From     int
+	To       int
+	Targets  map[string]int
+	Decision *policy.Decision
+	Hold     string
+	Err      error
+}
+
+func CreateSimulation(sp *policy.ScalingPolicy, replicas int, loadModel string, resources coreV1.ResourceRequirements) (*Simulation, error) {
+	if loadModel != LoadPerPod && loadModel != LoadTotal {
+		return nil, fmt.Errorf("unknown load model `%s`, expected `%s` or `%s`", loadModel, LoadPerPod, LoadTotal)
+	}
+
+	return &Simulation{
+		Policy:    sp,
+		Replicas:  replicas,
+		LoadModel: loadModel,
+		Resources: resources,
+		Start:     time.Unix(0, 0).UTC(),
+	}, nil
+}
+
+// Run steps a virtual clock at the policy's interval from the first to the
+// last sample, evaluating and scaling as the controller would on each tick.
+// Every target sees the same samples and starts at the simulation's replicas,
+// or its min when that is negative.
+func (s *Simulation) Run(ctx context.Context, samples []Sample) ([]Step, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples")
+	}
+
+	interval := time.Duration(s.Policy.CheckInterval) * time.Second
+	if interval <= 0 {
+		return nil, fmt.Errorf("policy interval must be positive")
+	}
+
+	first := time.Duration(samples[0].Time * float64(time.Second))
+	last := time.Duration(samples[len(samples)-1].Time * float64(time.Second))
+
+	s.Policy.LastScale = time.Time{}
+	s.Policy.State = nil
+	s.Policy.Evaluations = 0
+
+	replicas := make(map[string]int)
+	for _, pt := range s.Policy.Targets {
+		pt.History.Clear()
+
+		replicas[pt.Name] = s.Replicas
+		if s.Replicas < 0 {
+			replicas[pt.Name] = pt.Min
+		}
+	}
+
+	var steps []Step
+	next := 0
+	for offset := first; offset <= last; offset += interval {
+		for next+1 < len(samples) && time.Duration(samples[next+1].Time*float64(time.Second)) <= offset {
+			next++
+		}
+		sample := samples[next]
+
+		step := Step{
+			Time:   offset - first,
+			Sample: sample,
+			From:   s.TotalReplicas(replicas),
+			To:     s.TotalReplicas(replicas),
+		}
+		if s.Policy.MultiTarget() {
+			step.Targets = replicas
+		}
+
+		now := s.Start.Add(offset - first)
+		input, err := s.BuildInput(sample, replicas, now)
+		if err != nil {
+			return nil, err
+		}
+
+		_, decision, err := s.Policy.Evaluate(ctx, input)
+		if err != nil {
+			step.Err = err
+			steps = append(steps, step)
+			continue
+		}
+		step.Decision = decision
+
+		s.Policy.State = decision.State
+
+		scales, hold := s.Policy.Plan(decision, replicas, now)
+		step.Hold = hold
+		if hold == "" {
+			s.Policy.LastScale = now
+			replicas = scales
+		}
+		step.To = s.TotalReplicas(replicas)
+		if s.Policy.MultiTarget() {
+			step.Targets = replicas
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+func (s *Simulation) TotalReplicas(replicas map[string]int) int {
+	total := 0
+	for _, count := range replicas {
+		total += count
+	}
+	return total
+}
+
+func (s *Simulation) BuildInput(sample Sample, replicas map[string]int, now time.Time) (map[string]interface{}, error) {
+	targetInputs := make(map[string]*policy.TargetInput)
+	for _, pt := range s.Policy.Targets {
+		targetInput, err := s.BuildTargetInput(pt, sample, replicas[pt.Name], now)
+		if err != nil {
+			return nil, err
+		}
+		targetInputs[pt.Name] = targetInput
+	}
+
+	input := s.Policy.InputTargets(now, targetInputs)
+	input["state"] = s.Policy.InputState()
+
+	return input, nil
+}
+
+// BuildTargetInput creates the pods of a target, each reporting the sample as
+// its usage.
+func (s *Simulation) BuildTargetInput(pt *policy.PolicyTarget, sample Sample, replicas int, now time.Time) (*policy.TargetInput, error) {
+	cpu, err := s.PodUsage(sample.CPU, replicas)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpu sample at %vs: %v", sample.Time, err)
+	}
+
+	usage := coreV1.ResourceList{
+		coreV1.ResourceCPU: cpu,
+	}
+
+	if sample.Memory != "" {
+		memory, err := s.PodUsage(sample.Memory, replicas)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory sample at %vs: %v", sample.Time, err)
+		}
+		usage[coreV1.ResourceMemory] = memory
+	}
+
+	input := &policy.TargetInput{
+		Target:       s.BuildTarget(pt, replicas),
+		ExcludedPods: []policy.ExcludedPod{},
+	}
+
+	for i := 0; i < replicas; i++ {
+		name := fmt.Sprintf("%s-simulated-%d", pt.Ref.Name, i)
+
+		input.Pods = append(input.Pods, &coreV1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: s.Policy.Namespace,
+			},
+			Spec: coreV1.PodSpec{
+				Containers: []coreV1.Container{
+					{
+						Name:      "simulated",
+						Resources: s.Resources,
+					},
+				},
+			},
+			Status: coreV1.PodStatus{
+				Phase: coreV1.PodRunning,
+				Conditions: []coreV1.PodCondition{
+					{
+						Type:   coreV1.PodReady,
+						Status: coreV1.ConditionTrue,
+					},
+				},
+			},
+		})
+
+		input.PodMetrics = append(input.PodMetrics, &metricsv1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: s.Policy.Namespace,
+			},
+			Containers: []metricsv1beta1.ContainerMetrics{
+				{
+					Name:  "simulated",
+					Usage: usage,
+				},
+			},
+		})
+	}
+
+	return input, nil
+}
+
+// BuildTarget creates a fully rolled out workload of the target's kind.
+func (s *Simulation) BuildTarget(pt *policy.PolicyTarget, replicas int) *policy.Target {
+	specReplicas := int32(replicas)
+	objectMeta := metav1.ObjectMeta{
+		Name:      pt.Ref.Name,
+		Namespace: s.Policy.Namespace,
+	}
+
+	if !pt.Ref.IsBuiltin() {
+		target, _ := policy.CreateScaleTarget(pt.Ref, schema.GroupResource{}, &autoscalingV1.Scale{
+			ObjectMeta: objectMeta,
+			Spec: autoscalingV1.ScaleSpec{
+				Replicas: specReplicas,
+			},
+			Status: autoscalingV1.ScaleStatus{
+				Replicas: specReplicas,
+			},
+		}, nil)
+		return target
+	}
+
+	if pt.Ref.Kind == policy.KindStatefulSet {
+		return policy.CreateStatefulSetTarget(pt.Ref, &appsV1.StatefulSet{
+			ObjectMeta: objectMeta,
+			Spec: appsV1.StatefulSetSpec{
+				Replicas: &specReplicas,
+			},
+			Status: appsV1.StatefulSetStatus{
+				Replicas:        specReplicas,
+				ReadyReplicas:   specReplicas,
+				CurrentReplicas: specReplicas,
+				UpdatedReplicas: specReplicas,
+			},
+		})
+	}
+
+	return policy.CreateDeploymentTarget(pt.Ref, &appsV1.Deployment{
+		ObjectMeta: objectMeta,
+		Spec: appsV1.DeploymentSpec{
+			Replicas: &specReplicas,
+		},
+		Status: appsV1.DeploymentStatus{
+			Replicas:          specReplicas,
+			ReadyReplicas:     specReplicas,
+			AvailableReplicas: specReplicas,
+			UpdatedReplicas:   specReplicas,
+		},
+	})
+}
+
+func (s *Simulation) PodUsage(value string, replicas int) (resource.Quantity, error) {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return quantity, err
+	}
+
+	if s.LoadModel == LoadTotal && replicas > 0 {
+		return *resource.NewMilliQuantity(quantity.MilliValue()/int64(replicas), quantity.Format), nil
+	}
+
+	return quantity, nil
+}
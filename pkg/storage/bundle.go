@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/opa/bundle"
+)
+
+type BundleEntry struct {
+	URL     string
+	ETag    string
+	Version string
+	Bundle  *bundle.Bundle
+	Err     error
+}
+
+type BundleCache struct {
+	Client   *http.Client
+	Interval time.Duration
+	Timeout  time.Duration
+	Bundles  map[string]*BundleEntry
+	Mutex    sync.RWMutex
+}
+
+func CreateBundleCache(client *http.Client, interval time.Duration) *BundleCache {
+	return &BundleCache{
+		Client:   client,
+		Interval: interval,
+		Timeout:  30 * time.Second,
+		Bundles:  make(map[string]*BundleEntry),
+	}
+}
+
+func (b *BundleCache) Start(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(b.Interval):
+			b.Mutex.RLock()
+			var urls []string
+			for url := range b.Bundles {
+				urls = append(urls, url)
+			}
+			b.Mutex.RUnlock()
+
+			for _, url := range urls {
+				err := b.Refresh(ctx, url)
+				if err != nil {
+					fmt.Printf("Failed to fetch bundle %s: %v\n", url, err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// GetBundle returns the last fetched copy of a bundle. A url that has not
+// been seen before is fetched in the background and reported as not fetched
+// yet, so callers retry rather than wait on a slow server. Once seen, the url
+// is polled on Interval until it is forgotten.
+func (b *BundleCache) GetBundle(ctx context.Context, url string) (*BundleEntry, error) {
+	b.Mutex.Lock()
+	entry := b.Bundles[url]
+	if entry == nil {
+		entry = &BundleEntry{
+			URL: url,
+			Err: fmt.Errorf("bundle %s has not been fetched yet", url),
+		}
+		b.Bundles[url] = entry
+
+		go func(pending *BundleEntry) {
+			err := b.fetch(context.Background(), url, pending)
+			if err != nil {
+				fmt.Printf("Failed to fetch bundle %s: %v\n", url, err)
+			}
+		}(entry)
+	}
+	b.Mutex.Unlock()
+
+	if entry.Bundle == nil {
+		return nil, entry.Err
+	}
+
+	return entry, nil
+}
+
+func (b *BundleCache) GetBundleVersion(url string) string {
+	b.Mutex.RLock()
+	defer b.Mutex.RUnlock()
+
+	entry := b.Bundles[url]
+	if entry == nil {
+		return ""
+	}
+
+	return entry.Version
+}
+
+func (b *BundleCache) Forget(url string) {
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	delete(b.Bundles, url)
+}
+
+func (b *BundleCache) Fetch(ctx context.Context, url string) error {
+	b.Mutex.RLock()
+	previous := b.Bundles[url]
+	b.Mutex.RUnlock()
+
+	return b.fetch(ctx, url, previous)
+}
+
+// Refresh fetches a bundle again, unless it has been forgotten.
+func (b *BundleCache) Refresh(ctx context.Context, url string) error {
+	b.Mutex.RLock()
+	previous := b.Bundles[url]
+	b.Mutex.RUnlock()
+
+	if previous == nil {
+		return nil
+	}
+
+	return b.fetch(ctx, url, previous)
+}
+
+func (b *BundleCache) fetch(ctx context.Context, url string, previous *BundleEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, b.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil && previous.ETag != "" {
+		req.Header.Set("If-None-Match", previous.ETag)
+	}
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		b.setError(url, previous, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && previous != nil && previous.Bundle != nil {
+		return nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status %s fetching bundle %s", resp.Status, url)
+		b.setError(url, previous, err)
+		return err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		b.setError(url, previous, err)
+		return err
+	}
+
+	loaded, err := bundle.NewCustomReader(bundle.NewTarballLoader(bytes.NewReader(body))).Read()
+	if err != nil {
+		b.setError(url, previous, err)
+		return err
+	}
+
+	etag := resp.Header.Get("ETag")
+	version := etag
+	if version == "" {
+		version = fmt.Sprintf("%x", sha256.Sum256(body))
+	}
+
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	if b.forgotten(url, previous) {
+		return nil
+	}
+
+	b.Bundles[url] = &BundleEntry{
+		URL:     url,
+		ETag:    etag,
+		Version: version,
+		Bundle:  &loaded,
+	}
+
+	return nil
+}
+
+// setError records a failed fetch, keeping the last good bundle if there is
+// one so a flaky server doesn't cause policies to be recompiled.
+func (b *BundleCache) setError(url string, previous *BundleEntry, err error) {
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+
+	if previous != nil && previous.Bundle != nil {
+		return
+	}
+
+	if b.forgotten(url, previous) {
+		return
+	}
+
+	b.Bundles[url] = &BundleEntry{
+		URL: url,
+		Err: err,
+	}
+}
+
+// forgotten reports whether a url was forgotten while it was being fetched,
+// in which case the result is dropped rather than polled again.
+func (b *BundleCache) forgotten(url string, previous *BundleEntry) bool {
+	return previous != nil && b.Bundles[url] == nil
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+type ConfigMapCache struct {
+	Informer cache.SharedIndexInformer
+}
+
+func CreateConfigMapCache(informer cache.SharedIndexInformer) *ConfigMapCache {
+	configMapCache := &ConfigMapCache{
+		Informer: informer,
+	}
+
+	informer.AddEventHandler(configMapCache)
+	return configMapCache
+}
+
+func (c *ConfigMapCache) Start(ctx context.Context) {
+	c.Informer.Run(ctx.Done())
+}
+
+func (c *ConfigMapCache) GetConfigMap(namespace, name string) (*coreV1.ConfigMap, bool, error) {
+	item, exists, err := c.Informer.GetStore().GetByKey(fmt.Sprintf("%s/%s", namespace, name))
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !exists {
+		return nil, false, nil
+	}
+
+	return item.(*coreV1.ConfigMap), true, nil
+}
+
+func (c *ConfigMapCache) OnAdd(obj interface{}) {
+}
+
+func (c *ConfigMapCache) OnUpdate(oldObj, newObj interface{}) {
+}
+
+func (c *ConfigMapCache) OnDelete(obj interface{}) {
+}
@@ -6,10 +6,32 @@ import (
 	"sync"
 
 	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
 )
 
+const LabelIndex = "labels"
+
+// LabelIndexFunc indexes pods by `namespace/key=value` for each of their labels.
+func LabelIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*coreV1.Pod)
+	if !ok {
+		return nil, nil
+	}
+
+	var keys []string
+	for key, value := range pod.Labels {
+		keys = append(keys, LabelIndexKey(pod.Namespace, key, value))
+	}
+	return keys, nil
+}
+
+func LabelIndexKey(namespace, key, value string) string {
+	return fmt.Sprintf("%s/%s=%s", namespace, key, value)
+}
+
 type PodCache struct {
 	Informer   cache.SharedIndexInformer
 	OwnerCache map[types.UID]map[string]bool
@@ -23,6 +45,9 @@ func CreatePodCache(informer cache.SharedIndexInformer) *PodCache {
 	}
 
 	informer.AddEventHandler(podCache)
+	informer.AddIndexers(cache.Indexers{
+		LabelIndex: LabelIndexFunc,
+	})
 
 	return podCache
 }
@@ -43,6 +68,42 @@ func (p *PodCache) GetPodsByOwnerUID(uid types.UID) []string {
 	return pods
 }
 
+// GetPodsBySelector returns the names of the pods in a namespace matching the
+// selector, narrowing the search with the label index when the selector has an
+// equality requirement.
+func (p *PodCache) GetPodsBySelector(namespace string, selector labels.Selector) ([]string, error) {
+	indexName := cache.NamespaceIndex
+	indexKey := namespace
+
+	requirements, _ := selector.Requirements()
+	for _, requirement := range requirements {
+		operator := requirement.Operator()
+		if operator != selection.Equals && operator != selection.DoubleEquals {
+			continue
+		}
+		value, _ := requirement.Values().PopAny()
+		indexName = LabelIndex
+		indexKey = LabelIndexKey(namespace, requirement.Key(), value)
+		break
+	}
+
+	items, err := p.Informer.GetIndexer().ByIndex(indexName, indexKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []string
+	for _, item := range items {
+		pod := item.(*coreV1.Pod)
+		if pod.Namespace != namespace || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		pods = append(pods, pod.Name)
+	}
+
+	return pods, nil
+}
+
 func (p *PodCache) GetPod(namespace, name string) (*coreV1.Pod, bool, error) {
 	item, exists, err := p.Informer.GetStore().GetByKey(fmt.Sprintf("%s/%s", namespace, name))
 
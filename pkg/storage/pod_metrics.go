@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+type PodMetricsList struct {
+	Namespace string
+	Metrics   map[string]*metricsv1beta1.PodMetrics
+	Fetched   time.Time
+	Err       error
+	Mutex     sync.Mutex
+}
+
+// PodMetricsCache lists PodMetricses once per namespace and scrape period and
+// shares the result between every policy in that namespace.
+type PodMetricsCache struct {
+	Client metricsv.Interface
+	Period time.Duration
+	MaxAge time.Duration
+	Lists  map[string]*PodMetricsList
+	Mutex  sync.Mutex
+}
+
+func CreatePodMetricsCache(client metricsv.Interface, period time.Duration) *PodMetricsCache {
+	return &PodMetricsCache{
+		Client: client,
+		Period: period,
+		MaxAge: 2 * time.Minute,
+		Lists:  make(map[string]*PodMetricsList),
+	}
+}
+
+// Start drops namespaces which have not been read for a while.
+func (p *PodMetricsCache) Start(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(10 * time.Minute):
+			p.Mutex.Lock()
+			for namespace, list := range p.Lists {
+				list.Mutex.Lock()
+				if time.Since(list.Fetched) > 10*time.Minute {
+					delete(p.Lists, namespace)
+				}
+				list.Mutex.Unlock()
+			}
+			p.Mutex.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// GetPodMetrics returns the metrics of every pod in the namespace keyed by pod
+// name, listing them again once the last list is older than Period.
+func (p *PodMetricsCache) GetPodMetrics(ctx context.Context, namespace string) (map[string]*metricsv1beta1.PodMetrics, error) {
+	p.Mutex.Lock()
+	list := p.Lists[namespace]
+	if list == nil {
+		list = &PodMetricsList{Namespace: namespace}
+		p.Lists[namespace] = list
+	}
+	p.Mutex.Unlock()
+
+	list.Mutex.Lock()
+	defer list.Mutex.Unlock()
+
+	if time.Since(list.Fetched) < p.Period {
+		return list.Metrics, list.Err
+	}
+
+	podMetricsList, err := p.Client.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+	list.Fetched = time.Now()
+	if err != nil {
+		list.Err = err
+		return nil, err
+	}
+
+	metrics := make(map[string]*metricsv1beta1.PodMetrics, len(podMetricsList.Items))
+	for i := range podMetricsList.Items {
+		podMetrics := &podMetricsList.Items[i]
+		metrics[podMetrics.Name] = podMetrics
+	}
+
+	list.Metrics = metrics
+	list.Err = nil
+	return metrics, nil
+}
+
+// IsStale reports whether a sample is older than its window plus MaxAge, which
+// happens when metrics-server stops scraping a pod but still serves its last
+// sample.
+func (p *PodMetricsCache) IsStale(podMetrics *metricsv1beta1.PodMetrics, now time.Time) bool {
+	if podMetrics.Timestamp.IsZero() {
+		return false
+	}
+	return now.Sub(podMetrics.Timestamp.Time) > podMetrics.Window.Duration+p.MaxAge
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+type RegoLibraryCache struct {
+	Informer cache.SharedIndexInformer
+}
+
+func CreateRegoLibraryCache(informer cache.SharedIndexInformer) *RegoLibraryCache {
+	regoLibraryCache := &RegoLibraryCache{
+		Informer: informer,
+	}
+
+	informer.AddEventHandler(regoLibraryCache)
+	return regoLibraryCache
+}
+
+func (r *RegoLibraryCache) Start(ctx context.Context) {
+	r.Informer.Run(ctx.Done())
+}
+
+func (r *RegoLibraryCache) ListRegoLibraries() []interface{} {
+	return r.Informer.GetStore().List()
+}
+
+func (r *RegoLibraryCache) GetRegoLibrary(namespace, name string) (*unstructured.Unstructured, bool, error) {
+	item, exists, err := r.Informer.GetStore().GetByKey(fmt.Sprintf("%s/%s", namespace, name))
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !exists {
+		return nil, false, nil
+	}
+
+	return item.(*unstructured.Unstructured), true, nil
+}
+
+func (r *RegoLibraryCache) OnAdd(obj interface{}) {
+}
+
+func (r *RegoLibraryCache) OnUpdate(oldObj, newObj interface{}) {
+}
+
+func (r *RegoLibraryCache) OnDelete(obj interface{}) {
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ResourceKey identifies a resource watched in a single namespace, or in
+// every namespace for informers registered by the store itself.
+type ResourceKey struct {
+	GVR       schema.GroupVersionResource
+	Namespace string
+}
+
+type WatchedResource struct {
+	Informer cache.SharedIndexInformer
+	Refs     int
+	Cancel   context.CancelFunc
+}
+
+// ResourceCache watches arbitrary resources requested by policies. Each
+// resource and namespace gets its own informer, which is stopped once every
+// policy watching it has released it.
+type ResourceCache struct {
+	Client      dynamic.Interface
+	RESTMapper  meta.RESTMapper
+	Resources   map[ResourceKey]*WatchedResource
+	SyncTimeout time.Duration
+	Context     context.Context
+	Mutex       sync.Mutex
+}
+
+func CreateResourceCache(client dynamic.Interface) *ResourceCache {
+	return &ResourceCache{
+		Client:      client,
+		Resources:   make(map[ResourceKey]*WatchedResource),
+		SyncTimeout: 30 * time.Second,
+	}
+}
+
+func (r *ResourceCache) Start(ctx context.Context) {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	r.Context = ctx
+}
+
+// Register records an informer already run elsewhere so it is not started
+// twice. Registered informers watch every namespace and are never released.
+func (r *ResourceCache) Register(gvr schema.GroupVersionResource, informer cache.SharedIndexInformer) {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	r.Resources[ResourceKey{GVR: gvr}] = &WatchedResource{Informer: informer}
+}
+
+// Namespaced reports whether a resource is namespaced, rediscovering the API
+// once when it is unknown so CRDs installed after startup are found.
+func (r *ResourceCache) Namespaced(gvr schema.GroupVersionResource) (bool, error) {
+	if r.RESTMapper == nil {
+		return true, nil
+	}
+
+	gvk, err := r.RESTMapper.KindFor(gvr)
+	if ResetRESTMapper(r.RESTMapper, err) {
+		gvk, err = r.RESTMapper.KindFor(gvr)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	mapping, err := r.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, err
+	}
+
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// Watch takes a reference on the informer for a resource in a namespace,
+// starting it on first use. Every call must be paired with a Release.
+func (r *ResourceCache) Watch(gvr schema.GroupVersionResource, namespace string) error {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+
+	if watched := r.Resources[ResourceKey{GVR: gvr}]; watched != nil && watched.Cancel == nil {
+		return nil
+	}
+
+	key := ResourceKey{GVR: gvr, Namespace: namespace}
+	if watched := r.Resources[key]; watched != nil {
+		watched.Refs++
+		return nil
+	}
+
+	if r.Context == nil {
+		return fmt.Errorf("resource cache not started")
+	}
+
+	// an unknown resource would otherwise only fail once the sync times out
+	if r.RESTMapper != nil {
+		_, err := r.RESTMapper.KindFor(gvr)
+		if ResetRESTMapper(r.RESTMapper, err) {
+			_, err = r.RESTMapper.KindFor(gvr)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to watch %s: %v", gvr.String(), err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(r.Context)
+	informer := dynamicinformer.NewFilteredDynamicInformer(
+		r.Client, gvr, namespace, 0, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil,
+	).Informer()
+	go informer.Run(ctx.Done())
+
+	r.Resources[key] = &WatchedResource{
+		Informer: informer,
+		Refs:     1,
+		Cancel:   cancel,
+	}
+
+	return nil
+}
+
+// Release drops a reference taken by Watch, stopping the informer when it
+// was the last one.
+func (r *ResourceCache) Release(gvr schema.GroupVersionResource, namespace string) {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+
+	key := ResourceKey{GVR: gvr, Namespace: namespace}
+	watched := r.Resources[key]
+	if watched == nil || watched.Cancel == nil {
+		return
+	}
+
+	watched.Refs--
+	if watched.Refs > 0 {
+		return
+	}
+
+	watched.Cancel()
+	delete(r.Resources, key)
+}
+
+func (r *ResourceCache) Informer(gvr schema.GroupVersionResource, namespace string) (cache.SharedIndexInformer, error) {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+
+	if watched := r.Resources[ResourceKey{GVR: gvr, Namespace: namespace}]; watched != nil {
+		return watched.Informer, nil
+	}
+	if watched := r.Resources[ResourceKey{GVR: gvr}]; watched != nil {
+		return watched.Informer, nil
+	}
+
+	return nil, fmt.Errorf("%s is not watched in namespace %s", gvr.String(), namespace)
+}
+
+// GetResource returns a single object of a watched resource.
+func (r *ResourceCache) GetResource(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, bool, error) {
+	informer, err := r.WaitForResource(ctx, gvr, namespace)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := name
+	if namespace != metav1.NamespaceAll {
+		key = fmt.Sprintf("%s/%s", namespace, name)
+	}
+
+	item, exists, err := informer.GetStore().GetByKey(key)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+
+	return item.(*unstructured.Unstructured), true, nil
+}
+
+// WaitForResource returns the informer for a watched resource once it has
+// synced.
+func (r *ResourceCache) WaitForResource(ctx context.Context, gvr schema.GroupVersionResource, namespace string) (cache.SharedIndexInformer, error) {
+	informer, err := r.Informer(gvr, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if !informer.HasSynced() {
+		syncCtx, cancel := context.WithTimeout(ctx, r.SyncTimeout)
+		defer cancel()
+
+		if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+			return nil, fmt.Errorf("timed out waiting for %s to sync", gvr.String())
+		}
+	}
+
+	return informer, nil
+}
+
+// ListResources returns the objects of a watched resource matching the
+// selector, sorted by namespace and name.
+func (r *ResourceCache) ListResources(ctx context.Context, gvr schema.GroupVersionResource, namespace string, selector labels.Selector) ([]*unstructured.Unstructured, error) {
+	informer, err := r.WaitForResource(ctx, gvr, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if selector == nil {
+		selector = labels.Everything()
+	}
+
+	var result []*unstructured.Unstructured
+	appendFn := func(item interface{}) {
+		result = append(result, item.(*unstructured.Unstructured))
+	}
+
+	if namespace == metav1.NamespaceAll {
+		err = cache.ListAll(informer.GetIndexer(), selector, appendFn)
+	} else {
+		err = cache.ListAllByNamespace(informer.GetIndexer(), namespace, selector, appendFn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].GetNamespace() != result[j].GetNamespace() {
+			return result[i].GetNamespace() < result[j].GetNamespace()
+		}
+		return result[i].GetName() < result[j].GetName()
+	})
+
+	return result, nil
+}
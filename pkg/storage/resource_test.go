@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+var widgetGVR = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+func createWidget(namespace, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Widget",
+			"metadata": map[string]interface{}{
+				"namespace": namespace,
+				"name":      name,
+			},
+		},
+	}
+}
+
+func createTestResourceCache(ctx context.Context) *ResourceCache {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gizmo"}, meta.RESTScopeRoot)
+
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		createWidget("apps", "b"),
+		createWidget("apps", "a"),
+		createWidget("other", "c"),
+	)
+
+	resources := CreateResourceCache(client)
+	resources.RESTMapper = mapper
+	resources.Start(ctx)
+	return resources
+}
+
+func TestResourceCacheWatchRelease(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resources := createTestResourceCache(ctx)
+
+	_, err := resources.ListResources(ctx, widgetGVR, "apps", nil)
+	if err == nil {
+		t.Fatalf("expected an error listing an unwatched resource")
+	}
+
+	for i := 0; i < 2; i++ {
+		err = resources.Watch(widgetGVR, "apps")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	key := ResourceKey{GVR: widgetGVR, Namespace: "apps"}
+	if refs := resources.Resources[key].Refs; refs != 2 {
+		t.Fatalf("expected 2 references, got %d", refs)
+	}
+
+	items, err := resources.ListResources(ctx, widgetGVR, "apps", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, item := range items {
+		names = append(names, item.GetName())
+	}
+	if strings.Join(names, ",") != "a,b" {
+		t.Fatalf("expected widgets a,b in apps, got %v", names)
+	}
+
+	obj, exists, err := resources.GetResource(ctx, widgetGVR, "apps", "a")
+	if err != nil || !exists || obj.GetName() != "a" {
+		t.Fatalf("expected widget apps/a, got %v %v %v", obj, exists, err)
+	}
+
+	resources.Release(widgetGVR, "apps")
+	if resources.Resources[key] == nil {
+		t.Fatalf("informer stopped while still referenced")
+	}
+
+	resources.Release(widgetGVR, "apps")
+	if resources.Resources[key] != nil {
+		t.Fatalf("expected the informer to stop once released")
+	}
+
+	// releasing more than was watched is harmless
+	resources.Release(widgetGVR, "apps")
+}
+
+func TestResourceCacheUnknownResource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resources := createTestResourceCache(ctx)
+
+	err := resources.Watch(schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "gadgets"}, "apps")
+	if err == nil {
+		t.Fatalf("expected an error watching an unknown resource")
+	}
+	if len(resources.Resources) != 0 {
+		t.Fatalf("expected no informer to start, got %v", resources.Resources)
+	}
+}
+
+func TestResourceCacheRegistered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resources := createTestResourceCache(ctx)
+
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &unstructured.Unstructured{}, 0, cache.Indexers{})
+	resources.Register(widgetGVR, informer)
+
+	err := resources.Watch(widgetGVR, "apps")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resources.Release(widgetGVR, "apps")
+
+	found, err := resources.Informer(widgetGVR, "apps")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found != informer {
+		t.Fatalf("expected the registered informer to be reused")
+	}
+}
+
+func TestResourceCacheNamespaced(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resources := createTestResourceCache(ctx)
+
+	tests := []struct {
+		resource   string
+		namespaced bool
+		err        bool
+	}{
+		{resource: "widgets", namespaced: true},
+		{resource: "gizmos", namespaced: false},
+		{resource: "gadgets", err: true},
+	}
+
+	for _, test := range tests {
+		namespaced, err := resources.Namespaced(schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: test.resource})
+		if test.err {
+			if err == nil {
+				t.Fatalf("%s: expected an error", test.resource)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.resource, err)
+		}
+		if namespaced != test.namespaced {
+			t.Fatalf("%s: expected namespaced %v, got %v", test.resource, test.namespaced, namespaced)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	appsV1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+type StatefulSetCache struct {
+	Informer cache.SharedIndexInformer
+}
+
+func CreateStatefulSetCache(informer cache.SharedIndexInformer) *StatefulSetCache {
+	statefulSetCache := &StatefulSetCache{
+		Informer: informer,
+	}
+
+	informer.AddEventHandler(statefulSetCache)
+	return statefulSetCache
+}
+
+func (s *StatefulSetCache) Start(ctx context.Context) {
+	s.Informer.Run(ctx.Done())
+}
+
+func (s *StatefulSetCache) GetStatefulSet(namespace, name string) (*appsV1.StatefulSet, bool, error) {
+	item, exists, err := s.Informer.GetStore().GetByKey(fmt.Sprintf("%s/%s", namespace, name))
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !exists {
+		return nil, false, nil
+	}
+
+	return item.(*appsV1.StatefulSet), true, nil
+}
+
+func (s *StatefulSetCache) OnAdd(obj interface{}) {
+}
+
+func (s *StatefulSetCache) OnUpdate(oldObj, newObj interface{}) {
+}
+
+func (s *StatefulSetCache) OnDelete(obj interface{}) {
+}
@@ -2,13 +2,25 @@ package storage
 
 import (
 	"context"
+	"net/http"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/scale"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+	"k8s.io/metrics/pkg/client/custom_metrics"
+	"k8s.io/metrics/pkg/client/external_metrics"
 )
 
 type Store struct {
@@ -16,12 +28,25 @@ type Store struct {
 	MetricsClientset *metricsv.Clientset
 	DynamicClientset dynamic.Interface
 
-	DeploymentCache *DeploymentCache
-	ReplicaSetCache *ReplicaSetCache
-	PodCache        *PodCache
+	CustomMetricsClient   custom_metrics.CustomMetricsClient
+	CustomMetricsAPIs     custom_metrics.AvailableAPIsGetter
+	ExternalMetricsClient external_metrics.ExternalMetricsClient
+
+	RESTMapper  meta.RESTMapper
+	ScaleClient scale.ScalesGetter
+
+	DeploymentCache  *DeploymentCache
+	StatefulSetCache *StatefulSetCache
+	ReplicaSetCache  *ReplicaSetCache
+	PodCache         *PodCache
+	PodMetricsCache  *PodMetricsCache
+	ConfigMapCache   *ConfigMapCache
+	BundleCache      *BundleCache
 
 	ScalingPolicyCache       *ScalingPolicyCache
 	ScalingPolicyStatusCache *ScalingPolicyStatusCache
+	RegoLibraryCache         *RegoLibraryCache
+	ResourceCache            *ResourceCache
 }
 
 func NewStore(clientSet *kubernetes.Clientset, metricsClientset *metricsv.Clientset, dynamicClientset dynamic.Interface, factory informers.SharedInformerFactory, dynamicFactory dynamicinformer.DynamicSharedInformerFactory) *Store {
@@ -37,26 +62,129 @@ func NewStore(clientSet *kubernetes.Clientset, metricsClientset *metricsv.Client
 		Resource: "scalingpolicystatuses",
 	}
 
-	return &Store{
+	regoLibraryGVR := schema.GroupVersionResource{
+		Group:    "agronomist.io",
+		Version:  "v1",
+		Resource: "regolibraries",
+	}
+
+	store := &Store{
 		ClientSet:        clientSet,
 		MetricsClientset: metricsClientset,
 		DynamicClientset: dynamicClientset,
 
-		DeploymentCache: CreateDeploymentCache(factory.Apps().V1().Deployments().Informer()),
-		ReplicaSetCache: CreateReplicaSetCache(factory.Apps().V1().ReplicaSets().Informer()),
-		PodCache:        CreatePodCache(factory.Core().V1().Pods().Informer()),
+		DeploymentCache:  CreateDeploymentCache(factory.Apps().V1().Deployments().Informer()),
+		StatefulSetCache: CreateStatefulSetCache(factory.Apps().V1().StatefulSets().Informer()),
+		ReplicaSetCache:  CreateReplicaSetCache(factory.Apps().V1().ReplicaSets().Informer()),
+		PodCache:         CreatePodCache(factory.Core().V1().Pods().Informer()),
+		PodMetricsCache:  CreatePodMetricsCache(metricsClientset, 15*time.Second),
+		ConfigMapCache:   CreateConfigMapCache(factory.Core().V1().ConfigMaps().Informer()),
+		BundleCache:      CreateBundleCache(http.DefaultClient, time.Minute),
 
 		ScalingPolicyCache:       CreateScalingPolicyCache(dynamicFactory.ForResource(scalerGVR).Informer()),
 		ScalingPolicyStatusCache: CreateScalingPolicyStatusCache(dynamicFactory.ForResource(scalerStatusGVR).Informer()),
+		RegoLibraryCache:         CreateRegoLibraryCache(dynamicFactory.ForResource(regoLibraryGVR).Informer()),
+		ResourceCache:            CreateResourceCache(dynamicClientset),
 	}
 
+	store.ResourceCache.Register(scalerGVR, store.ScalingPolicyCache.Informer)
+	store.ResourceCache.Register(scalerStatusGVR, store.ScalingPolicyStatusCache.Informer)
+	store.ResourceCache.Register(regoLibraryGVR, store.RegoLibraryCache.Informer)
+
+	return store
 }
 
 func (s *Store) Start(ctx context.Context) {
 	go s.DeploymentCache.Start(ctx)
+	go s.StatefulSetCache.Start(ctx)
 	go s.ReplicaSetCache.Start(ctx)
 	go s.PodCache.Start(ctx)
+	go s.PodMetricsCache.Start(ctx)
+	go s.ConfigMapCache.Start(ctx)
+	go s.BundleCache.Start(ctx)
+
+	if s.CustomMetricsAPIs != nil {
+		go s.InvalidateCustomMetricsAPIs(ctx, 10*time.Minute)
+	}
 
 	go s.ScalingPolicyCache.Start(ctx)
 	go s.ScalingPolicyStatusCache.Start(ctx)
+	go s.RegoLibraryCache.Start(ctx)
+	s.ResourceCache.Start(ctx)
+}
+
+// InvalidateCustomMetricsAPIs periodically rediscovers the custom metrics api
+// version so adapters installed after startup are picked up.
+func (s *Store) InvalidateCustomMetricsAPIs(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-time.After(interval):
+			s.CustomMetricsAPIs.Invalidate()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Store) GetRegoLibrary(namespace, name string) (*unstructured.Unstructured, bool, error) {
+	return s.RegoLibraryCache.GetRegoLibrary(namespace, name)
+}
+
+func (s *Store) GetConfigMap(namespace, name string) (*coreV1.ConfigMap, bool, error) {
+	return s.ConfigMapCache.GetConfigMap(namespace, name)
+}
+
+// GetSecret reads a secret directly, rather than caching every secret in the
+// cluster for the few that inputs reference.
+func (s *Store) GetSecret(ctx context.Context, namespace, name string) (*coreV1.Secret, bool, error) {
+	secret, err := s.ClientSet.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return secret, true, nil
+}
+
+func (s *Store) GetBundle(ctx context.Context, url string) (*BundleEntry, error) {
+	return s.BundleCache.GetBundle(ctx, url)
+}
+
+func (s *Store) GetBundleVersion(url string) string {
+	return s.BundleCache.GetBundleVersion(url)
+}
+
+// RESTMapping maps a kind to its resource, rediscovering the API once when
+// the kind is unknown so CRDs installed after startup are found.
+func (s *Store) RESTMapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := s.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if ResetRESTMapper(s.RESTMapper, err) {
+		mapping, err = s.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	return mapping, err
+}
+
+// ResetRESTMapper drops the discovery cache of a mapper after a no match
+// error, reporting whether the lookup is worth retrying.
+func ResetRESTMapper(mapper meta.RESTMapper, err error) bool {
+	if err == nil || !meta.IsNoMatchError(err) {
+		return false
+	}
+
+	resettable, ok := mapper.(interface{ Reset() })
+	if !ok {
+		return false
+	}
+
+	resettable.Reset()
+	return true
+}
+
+func (s *Store) ListResources(ctx context.Context, gvr schema.GroupVersionResource, namespace string, selector labels.Selector) ([]*unstructured.Unstructured, error) {
+	return s.ResourceCache.ListResources(ctx, gvr, namespace, selector)
+}
+
+func (s *Store) GetResource(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, bool, error) {
+	return s.ResourceCache.GetResource(ctx, gvr, namespace, name)
 }
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// discoveringMapper only learns its kinds when it is reset, like a discovery
+// mapper whose cache predates a CRD.
+type discoveringMapper struct {
+	meta.RESTMapper
+	discovered *meta.DefaultRESTMapper
+	resets     int
+}
+
+func (d *discoveringMapper) Reset() {
+	d.resets++
+	d.RESTMapper = d.discovered
+}
+
+func TestStoreRESTMapping(t *testing.T) {
+	widget := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+
+	discovered := meta.NewDefaultRESTMapper(nil)
+	discovered.Add(widget, meta.RESTScopeNamespace)
+
+	mapper := &discoveringMapper{RESTMapper: meta.NewDefaultRESTMapper(nil), discovered: discovered}
+	store := &Store{RESTMapper: mapper}
+
+	mapping, err := store.RESTMapping(widget)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mapping.Resource.Resource != "widgets" {
+		t.Fatalf("expected widgets, got %v", mapping.Resource)
+	}
+	if mapper.resets != 1 {
+		t.Fatalf("expected one reset, got %d", mapper.resets)
+	}
+
+	_, err = store.RESTMapping(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"})
+	if err == nil || !meta.IsNoMatchError(err) {
+		t.Fatalf("expected a no match error, got %v", err)
+	}
+	if mapper.resets != 2 {
+		t.Fatalf("expected a single retry, got %d resets", mapper.resets)
+	}
+}
+
+func TestResetRESTMapper(t *testing.T) {
+	resettable := &discoveringMapper{RESTMapper: meta.NewDefaultRESTMapper(nil), discovered: meta.NewDefaultRESTMapper(nil)}
+	noMatch := &meta.NoKindMatchError{GroupKind: schema.GroupKind{Kind: "Widget"}}
+
+	tests := []struct {
+		name     string
+		mapper   meta.RESTMapper
+		err      error
+		expected bool
+	}{
+		{"no error", resettable, nil, false},
+		{"other error", resettable, fmt.Errorf("connection refused"), false},
+		{"no match", resettable, noMatch, true},
+		{"not resettable", meta.NewDefaultRESTMapper(nil), noMatch, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if reset := ResetRESTMapper(test.mapper, test.err); reset != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, reset)
+			}
+		})
+	}
+}